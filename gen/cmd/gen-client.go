@@ -4,13 +4,15 @@ package main
 // client
 
 import (
+	"bytes"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/oscarhealth/thriftgowrap/gen"
 )
@@ -18,71 +20,69 @@ import (
 var (
 	thriftFile  = flag.String("thrift", "", "Thrift file to generate clients for, relative to $DATA_REPO")
 	outFileName = flag.String("out", "", "Location to write the output to")
+	multiplexed = flag.Bool("multiplexed", false, "Multiplex calls with TMultiplexedProtocol by default")
+	typeCheck   = flag.Bool("typecheck", false, "Type-check the output against the thrift gen package before writing it")
+	check       = flag.Bool("check", false, "Print a diff and exit non-zero if the existing output is stale")
+	emitIR      = flag.String("emit-ir", "", "Write the parsed thrift files as JSON IR to this file, or - for stdout, instead of clients")
+	fromIR      = flag.String("ir", "", "Generate clients from JSON IR written by -emit-ir instead of thrift files")
+	all         = flag.Bool("all", false, "Generate clients for the services of all included thrift files too, "+
+		"each in the directory under -out matching its go namespace")
+	packagePrefix = flag.String("package-prefix", "", "Prefix of the import paths of thrift gen packages, like the thrift go generator's package_prefix option")
+	thriftImport  = flag.String("thrift-import", gen.DefaultThriftImport, "Import path of the thrift go library, like the thrift go generator's thrift_import option")
 )
 
-const goTemplate = `{{- $tPkg := .ThriftPackage -}}
-// Package {{.Package}} wraps {{.ThriftImport}} with RPC-specific logic.
-// @generated
-package {{.Package}}
+// templateFiles are the templates given with -template.
+var templateFiles stringsFlag
 
-import (
-	"git.apache.org/thrift.git/lib/go/thrift"
-{{range $import := .Imports}}
-	"{{$import}}"
-{{- end}}
-	"github.com/oscarhealth/thriftgowrap/utils/rpc"
-)
-{{range $service := .Services}}
-// {{$service.Name}}RPCClient implements {{$service.Name}} with RPC-specific logic.
-type {{$service.Name}}RPCClient rpc.Client
-
-// New{{$service.Name}}RPCClient returns a new {{$service.Name}}RPCClient.
-func New{{$service.Name}}RPCClient(transportFactory rpc.TransportFactory, options ...rpc.ClientOption) *{{$service.Name}}RPCClient {
-	client := rpc.NewClient(transportFactory, options...)
-	return (*{{$service.Name}}RPCClient)(client)
-}
+// stringsFlag is a flag that may be given several times.
+type stringsFlag []string
 
-// getThriftClient returns a {{$tPkg}}.{{$service.Name}}Client and thrift.TTransport.
-// The caller is expected to close the transport.
-func (c *{{$service.Name}}RPCClient) getThriftClient() ({{$tPkg}}.{{$service.Name}}, thrift.TTransport, error) {
-	transport, protocolFactory, err := c.TransportFactory.GetTransport()
-	if err != nil {
-		return nil, nil, err
-	}
-	return {{$tPkg}}.New{{$service.Name}}ClientFactory(transport, protocolFactory), transport, nil
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
 }
-{{- range $method := $service.Methods}}
-
-// {{$method.Name}} wraps the underlying method.
-func (c *{{$service.Name}}RPCClient) {{$method.Name}}({{$method.ArgDeclarations}}) (
-	{{- if $method.ResponseType}}resp {{$method.ResponseType}}, {{end}}err error) {
-	err = c.Retrier.Do(func() error {
-		client, transport, innerErr := c.getThriftClient()
-		if innerErr != nil {
-			return innerErr
-		}
-		defer transport.Close()
 
-		{{if $method.ResponseType}}resp, {{end}}err = client.{{$method.Name}}({{$method.Args}})
-		return err
-	})
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
 
-	return
+func init() {
+	flag.Var(&templateFiles, "template", "Template file overriding blocks of the built-in template, see gen.Template. May be given several times")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [thrift dirs or globs...]\n\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), "Given thrift dirs or globs, generates clients for the services of every matching file, "+
+			"each in the directory under -out matching its go namespace.\n\n")
+		flag.PrintDefaults()
+	}
 }
-{{- end}}
-{{end -}}
-`
 
 func generate(args *gen.Thrift, w io.Writer) error {
-	t := template.Must(template.New("template").Parse(goTemplate))
-	if err := t.Execute(w, args); err != nil {
+	t, err := gen.NewTemplate(templateFiles...)
+	if err != nil {
 		return err
 	}
-	return nil
+	var generated bytes.Buffer
+	if err := t.Execute(&generated, args); err != nil {
+		return err
+	}
+	formatted, err := gen.Format(args, generated.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
 }
 
 func main() {
 	flag.Parse()
+	if *fromIR != "" {
+		generateFromIR(*fromIR)
+		return
+	}
+	if flag.NArg() > 0 {
+		generateBatch(flag.Args())
+		return
+	}
 	fileName := *thriftFile
 	if !filepath.IsAbs(fileName) {
 		var err error
@@ -91,22 +91,167 @@ func main() {
 			log.Fatal(err)
 		}
 	}
-	goThrift, err := gen.NewParser(os.Getenv("GOPACKAGE")).Parse(fileName)
+	if *all {
+		generateAll(fileName)
+		return
+	}
+	goThrift, err := newParser(os.Getenv("GOPACKAGE")).Parse(fileName)
 	if err != nil {
-		log.Fatal(err)
+		report([]error{err})
+	}
+	if *emitIR != "" {
+		writeIR([]*gen.Thrift{goThrift}, nil)
+		return
 	}
+	goThrift.Multiplexed = *multiplexed
 	usedFileName := *outFileName
 	println(usedFileName)
 	if usedFileName == "" {
-		baseFile := filepath.Base(fileName)
-		usedFileName = baseFile[0:strings.LastIndex(baseFile, "thrift")] + "go"
+		usedFileName = goFileName(fileName)
 	}
-	outfile, err := os.Create(usedFileName)
-	if err != nil {
+	if err := write(goThrift, usedFileName); err != nil {
 		log.Fatal(err)
 	}
-	err = generate(goThrift, outfile)
+}
+
+// newParser returns a gen.Parser writing to pkg, configured by the flags.
+func newParser(pkg string) *gen.Parser {
+	return gen.NewParser(pkg, gen.PackagePrefixOption(*packagePrefix), gen.ThriftImportOption(*thriftImport))
+}
+
+// generateAll writes clients for the services of fileName and all the files it includes, each in
+// the directory under -out matching its go namespace.
+func generateAll(fileName string) {
+	goThrifts, err := newParser("").ParseAll(fileName)
+	if err != nil {
+		report([]error{err})
+	}
+	if *emitIR != "" {
+		writeIR(goThrifts, nil)
+		return
+	}
+	writeNamespaced(goThrifts, nil)
+}
+
+// generateBatch writes clients for the services of the thrift files matching patterns, like
+// generateAll. Patterns matching no thrift files are reported along with the files that fail.
+func generateBatch(patterns []string) {
+	fileNames, errs := gen.FindThriftFiles(patterns)
+	goThrifts, parseErrs := newParser("").ParseBatch(fileNames)
+	errs = append(errs, parseErrs...)
+	if *emitIR != "" {
+		writeIR(goThrifts, errs)
+		return
+	}
+	writeNamespaced(goThrifts, errs)
+}
+
+// generateFromIR writes clients for the thrift files in the JSON IR fileName. A single file is
+// written to -out if it names a go file, and files are written like generateAll otherwise.
+func generateFromIR(fileName string) {
+	irFile, err := os.Open(fileName)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer irFile.Close()
+	goThrifts, err := gen.ReadIR(irFile)
+	if err != nil {
+		log.Fatalf("%s: %v", fileName, err)
+	}
+	if len(goThrifts) == 1 && strings.HasSuffix(*outFileName, ".go") {
+		goThrifts[0].Multiplexed = *multiplexed
+		if err := write(goThrifts[0], *outFileName); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	writeNamespaced(goThrifts, nil)
+}
+
+// writeIR writes goThrifts as JSON IR to -emit-ir, reporting errs like writeNamespaced.
+func writeIR(goThrifts []*gen.Thrift, errs []error) {
+	out := os.Stdout
+	if *emitIR != "-" {
+		var err error
+		out, err = os.Create(*emitIR)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	if err := gen.WriteIR(out, goThrifts); err != nil {
+		errs = append(errs, err)
+	}
+	report(errs)
+}
+
+// writeNamespaced writes each of goThrifts into the directory under -out matching its go namespace.
+// Files that fail are reported along with errs without stopping the others, and the exit status is
+// non-zero if any did.
+func writeNamespaced(goThrifts []*gen.Thrift, errs []error) {
+	for _, goThrift := range goThrifts {
+		goThrift.Multiplexed = *multiplexed
+		namespace := strings.TrimPrefix(goThrift.ThriftImport, strings.TrimSuffix(*packagePrefix, "/")+"/")
+		dir := filepath.Join(*outFileName, filepath.FromSlash(namespace))
+		usedFileName := filepath.Join(dir, goFileName(goThrift.File))
+		var err error
+		if !*check {
+			// -check leaves the tree untouched, treating missing directories like missing files
+			err = os.MkdirAll(dir, 0755)
+		}
+		if err == nil {
+			err = write(goThrift, usedFileName)
+		}
+		if err != nil {
+			errs = append(errs, &gen.FileError{File: goThrift.File, Err: err})
+		}
+	}
+	report(errs)
+}
+
+// report prints errs to stderr one per line, like compilers do, exiting non-zero if there are any.
+func report(errs []error) {
+	for _, err := range errs {
+		// an ErrorList prints one error per line
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// goFileName returns the name of the go file generated for thriftFile, e.g. foo.go for foo.thrift.
+func goFileName(thriftFile string) string {
+	baseFile := filepath.Base(thriftFile)
+	return baseFile[0:strings.LastIndex(baseFile, "thrift")] + "go"
+}
+
+// write generates goThrift into fileName or, with -check, compares it with fileName.
+func write(goThrift *gen.Thrift, fileName string) error {
+	var generated bytes.Buffer
+	if err := generate(goThrift, &generated); err != nil {
+		return err
+	}
+	if *typeCheck {
+		if err := gen.TypeCheck(goThrift, fileName, generated.Bytes()); err != nil {
+			return err
+		}
+	}
+	if *check {
+		return checkFile(fileName, generated.Bytes())
+	}
+	return ioutil.WriteFile(fileName, generated.Bytes(), 0666)
+}
+
+// checkFile prints the diff between fileName and generated, returning an error if there is one.
+func checkFile(fileName string, generated []byte) error {
+	existing, err := ioutil.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if diff := gen.UnifiedDiff(fileName, fileName+" (generated)", existing, generated); diff != "" {
+		fmt.Print(diff)
+		return fmt.Errorf("%s is stale, run go generate", fileName)
+	}
+	return nil
 }
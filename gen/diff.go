@@ -0,0 +1,162 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes in a unified diff.
+const diffContext = 3
+
+// UnifiedDiff returns the unified diff turning a, named aName, into b, named bName, or the empty
+// string if they are equal.
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	// show every edit within diffContext of a change, each run of shown edits being a hunk
+	shown := make([]bool, len(edits))
+	for i, e := range edits {
+		if e.kind == ' ' {
+			continue
+		}
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(edits) {
+				shown[j] = true
+			}
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	aLine, bLine := 1, 1
+	for i := 0; i < len(edits); {
+		if !shown[i] {
+			aLine, bLine = advance(edits[i], aLine, bLine)
+			i++
+			continue
+		}
+		end := i
+		for end < len(edits) && shown[end] {
+			end++
+		}
+		aStart, bStart := aLine, bLine
+		for _, e := range edits[i:end] {
+			aLine, bLine = advance(e, aLine, bLine)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aLine-aStart), hunkRange(bStart, bLine-bStart))
+		for _, e := range edits[i:end] {
+			fmt.Fprintf(&out, "%c%s\n", e.kind, e.line)
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// edit is a line kept (' '), removed ('-') or added ('+') by a diff.
+type edit struct {
+	kind byte
+	line string
+}
+
+// advance returns the line numbers in a and b following e.
+func advance(e edit, aLine, bLine int) (int, int) {
+	if e.kind != '+' {
+		aLine++
+	}
+	if e.kind != '-' {
+		bLine++
+	}
+	return aLine, bLine
+}
+
+// hunkRange formats the range of lines a hunk covers in one file.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		// empty ranges name the line before them
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if length == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// noNewline marks a last line missing its line ending, so that it differs from the same line with
+// one and the diff notes it on the following line, like diff does.
+const noNewline = "\n\\ No newline at end of file"
+
+// splitLines splits text into lines, without their line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if !strings.HasSuffix(text, "\n") {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning a into b, using Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1) // the furthest x reached on each diagonal k = x - y, at v[k+offset]
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			x := v[k+1+offset] // move down, adding from b
+			if k != -d && (k == d || v[k-1+offset] >= v[k+1+offset]) {
+				x = v[k-1+offset] + 1 // move right, removing from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// walk back from the end, through the furthest reaching path of each step
+	edits := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k + 1
+		if k != -d && (k == d || v[k-1+offset] >= v[k+1+offset]) {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			edits = append(edits, edit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		edits = append(edits, edit{' ', a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,38 @@
+package gen
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	diffs := []struct {
+		a, b     string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "a\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\n", "", "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n13\n",
+			"--- old\n+++ new\n" +
+				"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+				"@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n 12\n+13\n",
+		},
+		{
+			"a\nb\nc\n",
+			"a\nc\nd\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n c\n+d\n",
+		},
+		{
+			"a\nb\n",
+			"a\nb",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tc := range diffs {
+		actual := UnifiedDiff("old", "new", []byte(tc.a), []byte(tc.b))
+		if actual != tc.expected {
+			t.Errorf("UnifiedDiff(%q, %q) => %q, want %q", tc.a, tc.b, actual, tc.expected)
+		}
+	}
+}
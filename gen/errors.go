@@ -0,0 +1,145 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParseError is a problem found in a thrift file, positioned at the declaration it was found in.
+type ParseError struct {
+	File    string
+	Line    int    // 1-based, or 0 if the position is unknown.
+	Column  int    // 1-based, in bytes, or 0 if the position is unknown.
+	Service string // The thrift name of the service, if any.
+	Method  string // The thrift name of the method, if any.
+	Msg     string
+}
+
+// Error formats e like compilers do, e.g. users.thrift:12:15: UserService.get: unknown type Usr.
+// The file is relative to the working directory when within it.
+func (e *ParseError) Error() string {
+	location := relativePath(e.File)
+	if e.Line > 0 {
+		location += fmt.Sprintf(":%d:%d", e.Line, e.Column)
+	}
+	switch {
+	case e.Method != "":
+		location += ": " + e.Service + "." + e.Method
+	case e.Service != "":
+		location += ": " + e.Service
+	}
+	return location + ": " + e.Msg
+}
+
+// relativePath returns file relative to the working directory if it is within it, and file
+// otherwise.
+func relativePath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	relative, err := filepath.Rel(wd, file)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return file
+	}
+	return relative
+}
+
+// ErrorList is all the problems found in a parse run.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// errorf records an error at ident within the declaration of the service and method being parsed.
+func (p *Parser) errorf(ident, format string, args ...interface{}) {
+	p.addError(p.mainFile, p.service, p.method, ident, fmt.Sprintf(format, args...))
+}
+
+// fileErrorf records an error at the first occurrence of ident in file.
+func (p *Parser) fileErrorf(file, ident, format string, args ...interface{}) {
+	p.addError(file, "", "", ident, fmt.Sprintf(format, args...))
+}
+
+func (p *Parser) addError(file, service, method, ident, message string) {
+	line, column := p.locate(file, service, method, ident)
+	p.errs = append(p.errs, &ParseError{
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Service: service,
+		Method:  method,
+		Msg:     message,
+	})
+}
+
+// takeErrors returns the errors recorded since it was last called, by position.
+func (p *Parser) takeErrors() ErrorList {
+	errs := p.errs
+	p.errs = nil
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Msg < b.Msg
+	})
+	list := make(ErrorList, len(errs))
+	for i, err := range errs {
+		list[i] = err
+	}
+	return list
+}
+
+// Declarations and identifiers in the code of thrift files, as searched by locate. Each captures
+// the name declared or used.
+var (
+	serviceDeclaration = regexp.MustCompile(`\bservice\s+(\w+)`)
+	methodDeclaration  = regexp.MustCompile(`(\w+)\s*\(`)
+	identifier         = regexp.MustCompile(`(\w+(?:\.\w+)*)`)
+)
+
+// locate returns the line and column of ident in file, searching from the declaration of service
+// and then of method, if given. The thrift parser does not keep positions, so they are read from
+// the source. It falls back to the position of the enclosing declaration, or 0, 0 if there is
+// none.
+func (p *Parser) locate(file, service, method, ident string) (line, column int) {
+	lines := p.source(file).code
+	start := 0
+	find := func(re *regexp.Regexp, found func(name string) bool) {
+		for i := start; i < len(lines); i++ {
+			for _, loc := range re.FindAllStringSubmatchIndex(lines[i], -1) {
+				if found(lines[i][loc[2]:loc[3]]) {
+					line, column, start = i+1, loc[0]+1, i
+					return
+				}
+			}
+		}
+	}
+	if service != "" {
+		find(serviceDeclaration, func(name string) bool { return name == service })
+	}
+	if method != "" {
+		find(methodDeclaration, func(name string) bool { return name == method })
+	}
+	if ident != "" {
+		// ident may also start a dotted name
+		find(identifier, func(name string) bool { return name == ident || strings.HasPrefix(name, ident+".") })
+	}
+	return line, column
+}
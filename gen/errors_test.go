@@ -0,0 +1,96 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+func TestParseErrors(t *testing.T) {
+	file, err := ioutil.TempFile("", "errors.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	source := `namespace go example.users
+
+struct User {
+  1: string name
+}
+
+service Users {
+  User get(1: i64 id) (cache_ttl = "soon"),
+
+  void put(
+    1: Usr user,
+  )
+}
+`
+	if _, err := file.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	p := &Parser{
+		mainFile: file.Name(),
+		thrift: map[string]*parser.Thrift{
+			file.Name(): {
+				Namespaces: map[string]string{"go": "example.users"},
+				Structs:    map[string]*parser.Struct{"User": {Name: "User"}},
+				Services: map[string]*parser.Service{"Users": {Name: "Users", Methods: map[string]*parser.Method{
+					"get": {
+						Name:        "get",
+						ReturnType:  &parser.Type{Name: "User"},
+						Arguments:   []*parser.Field{{ID: 1, Name: "id", Type: &parser.Type{Name: "i64"}}},
+						Annotations: []*parser.Annotation{{Name: "cache_ttl", Value: "soon"}},
+					},
+					"put": {
+						Name:      "put",
+						Arguments: []*parser.Field{{ID: 1, Name: "user", Type: &parser.Type{Name: "Usr"}}},
+					},
+				}}},
+			},
+		},
+	}
+
+	thrift, err := p.parseFile(file.Name(), "users")
+	if thrift != nil {
+		t.Errorf("expected no Thrift, received %+v", thrift)
+	}
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected both errors to be returned, received %v", err)
+	}
+	expected := []*ParseError{
+		{File: file.Name(), Line: 8, Column: 24, Service: "Users", Method: "get", Msg: `invalid cache_ttl "soon"`},
+		{File: file.Name(), Line: 11, Column: 8, Service: "Users", Method: "put", Msg: "unknown type Usr"},
+	}
+	for i, err := range errs {
+		if *err.(*ParseError) != *expected[i] {
+			t.Errorf("errs[%d] => %+v, want %+v", i, err, expected[i])
+		}
+	}
+	if message := errs[1].Error(); message != file.Name()+":11:8: Users.put: unknown type Usr" {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+func TestParseError_RelativePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseErr := &ParseError{File: filepath.Join(wd, "idl", "users.thrift"), Line: 12, Column: 8, Msg: "unknown type Usr"}
+	if message, expected := parseErr.Error(), filepath.Join("idl", "users.thrift")+":12:8: unknown type Usr"; message != expected {
+		t.Errorf("expected files within the working directory to be relative, received %q, want %q", message, expected)
+	}
+
+	outside := filepath.Join(filepath.Dir(wd), "users.thrift")
+	parseErr = &ParseError{File: outside, Msg: "unknown type Usr"}
+	if message := parseErr.Error(); message != outside+": unknown type Usr" {
+		t.Errorf("expected files outside the working directory to stay absolute, received %q", message)
+	}
+}
@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FindThriftFiles expands patterns into the absolute paths of the thrift files they match, sorted.
+// A pattern is either a directory, searched recursively for .thrift files, or a glob as accepted by
+// filepath.Glob, of which only .thrift files are kept. Patterns that fail or match no thrift files
+// are returned as an error each, along with the files matched by the others.
+func FindThriftFiles(patterns []string) ([]string, []error) {
+	found := map[string]bool{}
+	var errs []error
+	for _, pattern := range patterns {
+		files, err := findThriftFiles(pattern)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("no thrift files match %q", pattern)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, file := range files {
+			found[file] = true
+		}
+	}
+
+	files := make([]string, 0, len(found))
+	for file := range found {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, errs
+}
+
+// findThriftFiles returns the absolute paths of the thrift files matched by pattern.
+func findThriftFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %v", pattern, err)
+	}
+	var files []string
+	for _, match := range matches {
+		err := filepath.Walk(match, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(file) != ".thrift" {
+				return nil
+			}
+			file, err = filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,49 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindThriftFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, file := range []string{"a.thrift", "b.thrift", "notes.txt", "nested/c.thrift", "nested/deeper/d.thrift"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, errs := FindThriftFiles([]string{filepath.Join(dir, "nested"), filepath.Join(dir, "*"), filepath.Join(dir, "a.thrift")})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	expected := []string{
+		filepath.Join(dir, "a.thrift"),
+		filepath.Join(dir, "b.thrift"),
+		filepath.Join(dir, "nested/c.thrift"),
+		filepath.Join(dir, "nested/deeper/d.thrift"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("FindThriftFiles => %v, want %v", files, expected)
+	}
+
+	// patterns matching no thrift files fail on their own
+	files, errs = FindThriftFiles([]string{filepath.Join(dir, "missing/*.thrift"), filepath.Join(dir, "*.txt"), filepath.Join(dir, "b.thrift")})
+	if len(errs) != 2 {
+		t.Errorf("expected an error for each pattern matching no thrift files, received %v", errs)
+	}
+	if expected := []string{filepath.Join(dir, "b.thrift")}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("FindThriftFiles => %v, want %v", files, expected)
+	}
+}
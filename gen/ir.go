@@ -0,0 +1,36 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// IRVersion is the version of the JSON representation written by WriteIR. It changes whenever a
+// change to the representation could break its readers; adding fields does not.
+const IRVersion = 1
+
+// IR is the JSON intermediate representation of parsed thrift files, for other tools to consume.
+type IR struct {
+	Version int       `json:"version"`
+	Files   []*Thrift `json:"files"`
+}
+
+// WriteIR writes thrifts to w as a versioned JSON IR.
+func WriteIR(w io.Writer, thrifts []*Thrift) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&IR{Version: IRVersion, Files: thrifts})
+}
+
+// ReadIR reads thrifts written by WriteIR from r, so they can be rendered like parsed ones.
+func ReadIR(r io.Reader) ([]*Thrift, error) {
+	ir := &IR{}
+	if err := json.NewDecoder(r).Decode(ir); err != nil {
+		return nil, err
+	}
+	if ir.Version != IRVersion {
+		return nil, fmt.Errorf("unsupported IR version %d, want %d", ir.Version, IRVersion)
+	}
+	return ir.Files, nil
+}
@@ -0,0 +1,53 @@
+package gen
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIR(t *testing.T) {
+	thrifts := []*Thrift{{
+		File:          "/example.thrift",
+		Package:       "example",
+		ThriftPackage: "services",
+		ThriftImport:  "example/services",
+		Imports:       []string{"example/services"},
+		Services: []*Service{{
+			Name: "Example", ThriftName: "example",
+			Annotations: map[string]string{"owner": "search"},
+			Methods: []*Method{{
+				Name: "Get", ThriftName: "get", ArgsStruct: "ExampleGetArgs", ResponseType: "string",
+				Request:     []*Arg{{Name: "id", Type: "int64", FieldName: "ID"}},
+				Exceptions:  []*Arg{{Name: "missing", Type: "*services.NotFound", FieldName: "Missing"}},
+				CacheTTL:    30 * time.Second,
+				Annotations: map[string]string{"cache_ttl": "30s"},
+				Doc:         "Get gets.",
+			}},
+		}},
+	}}
+
+	var ir bytes.Buffer
+	if err := WriteIR(&ir, thrifts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{`"version": 1`, `"responseType": "string"`, `"cache_ttl": "30s"`} {
+		if !strings.Contains(ir.String(), expected) {
+			t.Errorf("expected the IR to contain %s, received:\n%s", expected, ir.String())
+		}
+	}
+
+	read, err := ReadIR(&ir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, thrifts) {
+		t.Errorf("expected to read back what was written, received %+v", read)
+	}
+
+	if _, err := ReadIR(strings.NewReader(`{"version": 2, "files": []}`)); err == nil {
+		t.Error("expected an error for unsupported versions")
+	}
+}
@@ -0,0 +1,230 @@
+package gen
+
+import (
+	"path"
+	"strings"
+)
+
+// The naming rules below are ported from the thrift go generator, see publicize, camelcase,
+// fix_common_initialism and variable_name_to_go_name in
+// https://github.com/apache/thrift/blob/master/compiler/cpp/src/thrift/generate/t_go_generator.cc.
+// Like the generator they work on ASCII bytes, leaving any other bytes as they are.
+
+// commonInitialisms are upper-cased when they make up a whole word of a name.
+var commonInitialisms = map[string]bool{
+	"API":   true,
+	"ASCII": true,
+	"CPU":   true,
+	"CSS":   true,
+	"DNS":   true,
+	"EOF":   true,
+	"GUID":  true,
+	"HTML":  true,
+	"HTTP":  true,
+	"HTTPS": true,
+	"ID":    true,
+	"IP":    true,
+	"JSON":  true,
+	"LHS":   true,
+	"QPS":   true,
+	"RAM":   true,
+	"RHS":   true,
+	"RPC":   true,
+	"SLA":   true,
+	"SMTP":  true,
+	"SSH":   true,
+	"TCP":   true,
+	"TLS":   true,
+	"TTL":   true,
+	"UDP":   true,
+	"UI":    true,
+	"UID":   true,
+	"UUID":  true,
+	"URI":   true,
+	"URL":   true,
+	"UTF8":  true,
+	"VM":    true,
+	"XML":   true,
+	"XSRF":  true,
+	"XSS":   true,
+}
+
+// goKeywords are the names the generator escapes when used as variables. error is not a keyword
+// but is escaped all the same.
+var goKeywords = map[string]bool{
+	"break":       true,
+	"case":        true,
+	"chan":        true,
+	"const":       true,
+	"continue":    true,
+	"default":     true,
+	"defer":       true,
+	"else":        true,
+	"error":       true,
+	"fallthrough": true,
+	"for":         true,
+	"func":        true,
+	"go":          true,
+	"goto":        true,
+	"if":          true,
+	"import":      true,
+	"interface":   true,
+	"map":         true,
+	"package":     true,
+	"range":       true,
+	"return":      true,
+	"select":      true,
+	"struct":      true,
+	"switch":      true,
+	"type":        true,
+	"var":         true,
+}
+
+// publicize returns the exported go name the generator gives a thrift type, service, method or
+// field name.
+func publicize(name string) string {
+	return publicizeName(name, false)
+}
+
+// argsStructName returns the name of the struct the generator holds the args of method in.
+func argsStructName(service, method string) string {
+	return publicize(service) + publicizeName(method+"_args", true)
+}
+
+// resultStructName returns the name of the struct the generator holds the result of method in.
+func resultStructName(service, method string) string {
+	return publicize(service) + publicizeName(method+"_result", true)
+}
+
+// publicizeName is publicize, skipping the Args and Result suffix escaping for the names of the
+// generator's own args and result structs.
+func publicizeName(name string, isArgsOrResult bool) string {
+	if name == "" {
+		return name
+	}
+	prefix := ""
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		prefix, name = name[:dot+1], name[dot+1:]
+	}
+	b := []byte(name)
+	if len(b) > 0 {
+		b[0] = toUpper(b[0])
+	}
+	camelcased := camelcase(b)
+
+	publicized := camelcased
+	// names starting with New would collide with constructors
+	if strings.HasPrefix(camelcased, "New") {
+		publicized += "_"
+	}
+	// names ending with Args or Result would collide with the args and result structs
+	if !isArgsOrResult && (strings.HasSuffix(camelcased, "Args") || strings.HasSuffix(camelcased, "Result")) {
+		publicized += "_"
+	}
+	return prefix + publicized
+}
+
+// camelcase upper-cases lower case letters following an underscore, dropping the underscore, and
+// upper-cases words that are common initialisms.
+func camelcase(b []byte) string {
+	b = fixCommonInitialism(b, 0)
+	for i := 1; i < len(b)-1; i++ {
+		if b[i] != '_' {
+			continue
+		}
+		if isLower(b[i+1]) {
+			b = append(append(b[:i:i], toUpper(b[i+1])), b[i+2:]...)
+		}
+		b = fixCommonInitialism(b, i)
+	}
+	return string(b)
+}
+
+// fixCommonInitialism upper-cases the word of b starting at i, up to the next underscore, if it
+// is a common initialism.
+func fixCommonInitialism(b []byte, i int) []byte {
+	end := len(b)
+	if n := strings.IndexByte(string(b[i:]), '_'); n >= 0 {
+		end = i + n
+	}
+	word := make([]byte, end-i)
+	for j := range word {
+		word[j] = toUpper(b[i+j])
+	}
+	if commonInitialisms[string(word)] {
+		copy(b[i:], word)
+	}
+	return b
+}
+
+// goVarName returns the name the generator gives a thrift argument in go signatures, escaping go
+// keywords.
+func goVarName(name string) string {
+	lower := strings.ToLower(name)
+	if goKeywords[lower] {
+		return lower + "_a1"
+	}
+	return name
+}
+
+// predeclared are go's predeclared identifiers, which args would shadow.
+var predeclared = map[string]bool{
+	"any": true, "append": true, "bool": true, "byte": true, "cap": true, "close": true,
+	"complex": true, "complex64": true, "complex128": true, "copy": true, "delete": true,
+	"false": true, "float32": true, "float64": true, "imag": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "iota": true, "len": true, "make": true,
+	"new": true, "nil": true, "panic": true, "print": true, "println": true, "real": true,
+	"recover": true, "rune": true, "string": true, "true": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+}
+
+// templateNames are the packages the built-in template always imports and the names it declares
+// in method bodies, which args would shadow.
+var templateNames = []string{
+	"context", "time", "thrift", "rpc",
+	"c", "client", "ctx", "err", "resp", "result", "transport", "protocolFactory",
+}
+
+// sanitizeArgs sets the GoName of the args of services to names that are valid go identifiers
+// and don't shadow predeclared identifiers, the packages in imports or the template's own names.
+func sanitizeArgs(services []*Service, imports []string) {
+	reserved := map[string]bool{"_": true} // the blank identifier can't be passed on
+	for _, name := range templateNames {
+		reserved[name] = true
+	}
+	for _, imported := range imports {
+		reserved[path.Base(imported)] = true
+	}
+	for _, service := range services {
+		for _, method := range service.Methods {
+			used := map[string]bool{}
+			for _, arg := range method.Request {
+				name := goVarName(arg.Name)
+				for predeclared[name] || reserved[name] || used[name] {
+					name += "_"
+				}
+				used[name] = true
+				arg.GoName = name
+			}
+		}
+	}
+}
+
+// VarName returns the name of the arg in generated signatures.
+func (a *Arg) VarName() string {
+	if a.GoName != "" {
+		return a.GoName
+	}
+	return goVarName(a.Name)
+}
+
+func isLower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func toUpper(c byte) byte {
+	if isLower(c) {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,120 @@
+package gen
+
+import "testing"
+
+// Expected names below are those the thrift go generator gives the same thrift names.
+
+func TestPublicize(t *testing.T) {
+	names := []struct {
+		in       string
+		expected string
+	}{
+		// simple cases
+		{"t", "T"},
+		{"baseCase", "BaseCase"},
+		{"userId", "UserId"},
+		// check snaking coverage
+		{"snake_case", "SnakeCase"},
+		{"foo_", "Foo_"},
+		{"foo_1", "Foo_1"},
+		{"_foo", "_foo"},
+		{"a__b", "A_B"},
+		{"my_Id", "My_Id"},
+		// check initialisms
+		{"snake_json", "SnakeJSON"},
+		{"json_snake", "JSONSnake"},
+		{"s_json_snake", "SJSONSnake"},
+		{"user_id", "UserID"},
+		{"id", "ID"},
+		{"ids", "Ids"},
+		{"http_url", "HTTPURL"},
+		{"api_v2", "APIV2"},
+		{"utf8_string", "UTF8String"},
+		// initialisms are matched ignoring case
+		{"jSon_snake", "JSONSnake"},
+		// key word edge cases
+		{"Args", "Args_"},
+		{"AResult", "AResult_"},
+		{"get_result", "GetResult_"},
+		{"result_id", "ResultID"},
+		{"new_user", "NewUser_"},
+		{"Newspaper", "Newspaper_"},
+		{"renew", "Renew"},
+		// combo snake, initialism, key word
+		{"json_args", "JSONArgs_"},
+		{"new_args", "NewArgs__"},
+		// included types keep their package
+		{"shared.user_type", "shared.UserType"},
+		{"", ""},
+	}
+
+	for _, tc := range names {
+		actual := publicize(tc.in)
+		if actual != tc.expected {
+			t.Errorf("publicize(%q) => %q, want %q", tc.in, actual, tc.expected)
+		}
+	}
+}
+
+func TestArgsAndResultStructNames(t *testing.T) {
+	names := []struct {
+		service, method string
+		args, result    string
+	}{
+		{"Calculator", "add", "CalculatorAddArgs", "CalculatorAddResult"},
+		{"Calculator", "get_result", "CalculatorGetResultArgs", "CalculatorGetResultResult"},
+		{"Calculator", "new_user", "CalculatorNewUserArgs_", "CalculatorNewUserResult_"},
+		{"news_service", "get", "NewsService_GetArgs", "NewsService_GetResult"},
+		{"json_api", "get_id", "JSONAPIGetIDArgs", "JSONAPIGetIDResult"},
+	}
+
+	for _, tc := range names {
+		if actual := argsStructName(tc.service, tc.method); actual != tc.args {
+			t.Errorf("argsStructName(%q, %q) => %q, want %q", tc.service, tc.method, actual, tc.args)
+		}
+		if actual := resultStructName(tc.service, tc.method); actual != tc.result {
+			t.Errorf("resultStructName(%q, %q) => %q, want %q", tc.service, tc.method, actual, tc.result)
+		}
+	}
+}
+
+func TestGoVarName(t *testing.T) {
+	names := []struct {
+		in       string
+		expected string
+	}{
+		{"id", "id"},
+		{"typeName", "typeName"},
+		{"type", "type_a1"},
+		{"Type", "type_a1"},
+		{"error", "error_a1"},
+		{"Map", "map_a1"},
+		{"range", "range_a1"},
+	}
+
+	for _, tc := range names {
+		actual := goVarName(tc.in)
+		if actual != tc.expected {
+			t.Errorf("goVarName(%q) => %q, want %q", tc.in, actual, tc.expected)
+		}
+	}
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	args := []*Arg{
+		{Name: "type"}, {Name: "err"}, {Name: "c"}, {Name: "c_"}, {Name: "len"},
+		{Name: "users"}, {Name: "context"}, {Name: "_"}, {Name: "id"},
+	}
+	services := []*Service{{Methods: []*Method{{Request: args}}}}
+	sanitizeArgs(services, []string{"example/users", "example/main"})
+
+	expected := []string{"type_a1", "err_", "c_", "c__", "len_", "users_", "context_", "__", "id"}
+	for i, arg := range args {
+		if arg.VarName() != expected[i] {
+			t.Errorf("VarName() of %q => %q, want %q", arg.Name, arg.VarName(), expected[i])
+		}
+	}
+	if unsanitized := (&Arg{Name: "Type"}); unsanitized.VarName() != "type_a1" {
+		t.Errorf("expected args without a GoName to escape keywords, received %q", unsanitized.VarName())
+	}
+}
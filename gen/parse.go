@@ -2,73 +2,218 @@ package gen
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
-	"unicode"
+	"time"
 
 	"github.com/alecthomas/go-thrift/parser"
 )
 
 // Arg is a named function argument.
 type Arg struct {
-	Name string
-	Type string
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	FieldName string  `json:"fieldName"`        // The name of the field holding the arg in the thrift gen args struct.
+	GoName    string  `json:"goName,omitempty"` // The name of the arg in generated signatures, see VarName.
+	Doc       string  `json:"doc,omitempty"`
+	Struct    *Struct `json:"struct,omitempty"` // The struct of the arg, if it is one.
 }
 
 // Method is a method call on a service.
 type Method struct {
-	Name         string
-	Request      []*Arg
-	ResponseType string // empty string => void
+	Name         string `json:"name"`
+	ThriftName   string `json:"thriftName"` // The method name as declared in the thrift file.
+	ArgsStruct   string `json:"argsStruct"` // The name of the thrift gen struct holding the method's args.
+	Request      []*Arg `json:"request"`
+	ResponseType string `json:"responseType,omitempty"` // empty string => void
+	Idempotent   bool   `json:"idempotent,omitempty"`   // Annotated with (idempotent="true"), so calls may be hedged.
+	ReadOnly     bool   `json:"readOnly,omitempty"`     // Annotated with (read_only="true"), so calls may be coalesced.
+
+	// CacheTTL is how long results may be cached for, annotated with e.g. (cache_ttl="30s").
+	CacheTTL time.Duration `json:"cacheTTL,omitempty"`
+
+	// Sensitive lists the args, and fields nested within them, annotated with (sensitive="true"),
+	// as dot-separated paths of thrift names.
+	Sensitive []string `json:"sensitive,omitempty"`
+	// Required lists the required fields nested within args, as dot-separated paths of thrift names.
+	Required []string `json:"required,omitempty"`
+
+	Exceptions  []*Arg            `json:"exceptions,omitempty"`  // The exceptions declared by the method.
+	Annotations map[string]string `json:"annotations,omitempty"` // All annotations of the method.
+	Doc         string            `json:"doc,omitempty"`         // The doc comment of the method.
 }
 
 // Service is a thrift service.
 type Service struct {
-	Name    string
-	Methods []*Method
+	Name        string            `json:"name"`
+	ThriftName  string            `json:"thriftName"` // The service name as declared in the thrift file.
+	Methods     []*Method         `json:"methods"`
+	Annotations map[string]string `json:"annotations,omitempty"` // All annotations of the service.
+	Doc         string            `json:"doc,omitempty"`         // The doc comment of the service.
 }
 
 // Thrift is a single thrift file.
 type Thrift struct {
-	Package       string   // The go package to write the generated file to.
-	ThriftPackage string   // The package of the thrift gen code.
-	ThriftImport  string   // The import path to the thrift gen code.
-	Imports       []string // All imports used in the servies.
-	Services      []*Service
+	File          string     `json:"file"`          // The absolute path of the thrift file.
+	Package       string     `json:"package"`       // The go package to write the generated file to.
+	ThriftPackage string     `json:"thriftPackage"` // The package of the thrift gen code.
+	ThriftImport  string     `json:"thriftImport"`  // The import path to the thrift gen code.
+	Imports       []string   `json:"imports"`       // All imports used in the servies.
+	Services      []*Service `json:"services"`
+
+	// LibraryImport is the import path of the thrift go library, see ThriftImportOption.
+	LibraryImport string `json:"libraryImport,omitempty"`
+
+	// Multiplexed clients wrap their protocols with thrift.TMultiplexedProtocol by default.
+	Multiplexed bool `json:"-"`
+}
+
+// Caches returns whether any method of any service has a CacheTTL.
+func (t *Thrift) Caches() bool {
+	for _, service := range t.Services {
+		for _, method := range service.Methods {
+			if method.CacheTTL > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CacheTTLExpr returns the go expression for the method's CacheTTL, e.g. 30 * time.Second.
+func (m *Method) CacheTTLExpr() string {
+	units := []struct {
+		duration time.Duration
+		name     string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+	}
+	for _, unit := range units {
+		if m.CacheTTL%unit.duration == 0 {
+			return fmt.Sprintf("%d * %s", m.CacheTTL/unit.duration, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", m.CacheTTL)
 }
 
 // ArgDeclarations returns the declarations for all args.
 func (m *Method) ArgDeclarations() string {
 	results := make([]string, len(m.Request))
 	for i, argument := range m.Request {
-		results[i] = argument.Name + " " + argument.Type
+		results[i] = argument.VarName() + " " + argument.Type
 	}
 	return strings.Join(results, ", ")
 }
 
+// ArgDocs returns a list of the documented args, named as in generated signatures, with their
+// docs, one per line, or the empty string if none are documented.
+func (m *Method) ArgDocs() string {
+	results := []string{}
+	for _, argument := range m.Request {
+		if argument.Doc != "" {
+			results = append(results, "  - "+argument.VarName()+": "+strings.Replace(argument.Doc, "\n", "\n    ", -1))
+		}
+	}
+	return strings.Join(results, "\n")
+}
+
+// Requires returns whether any of the Required fields are nested within arg.
+func (m *Method) Requires(arg *Arg) bool {
+	for _, path := range m.Required {
+		if strings.HasPrefix(path, arg.Name+".") {
+			return true
+		}
+	}
+	return false
+}
+
 // Args returns a list of all args, without types.
 func (m *Method) Args() string {
 	results := make([]string, len(m.Request))
 	for i, argument := range m.Request {
-		results[i] = argument.Name
+		results[i] = argument.VarName()
 	}
 	return strings.Join(results, ", ")
 }
 
+// ArgFields returns the field initializers of the thrift gen args struct for all args.
+func (m *Method) ArgFields() string {
+	results := make([]string, len(m.Request))
+	for i, argument := range m.Request {
+		results[i] = argument.FieldName + ": " + argument.VarName()
+	}
+	return strings.Join(results, ", ")
+}
+
+// FileError is an error parsing a thrift file.
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return relativePath(e.File) + ": " + e.Err.Error()
+}
+
 // Parser parses thrift files into a Thrift object.  It is not threadsafe.
 type Parser struct {
-	pkg string
+	pkg           string
+	packagePrefix string
+	thriftImport  string
 
 	// these only exist during a parse run
-	imports  map[string]bool
-	thrift   map[string]*parser.Thrift
-	mainFile string
+	imports      map[string]bool
+	thrift       map[string]*parser.Thrift
+	mainFile     string
+	required     map[string]map[string]map[int]bool // see requiredFieldIDs
+	symbolTables map[string]map[string]symbolKind   // see symbols
+	sources      map[string]*source                 // see source
+
+	// the service and method being parsed, and the errors found, see errorf
+	service string
+	method  string
+	errs    []*ParseError
 }
 
+// DefaultThriftImport is the import path of the thrift go library used unless a
+// ThriftImportOption is given.
+const DefaultThriftImport = "git.apache.org/thrift.git/lib/go/thrift"
+
 // NewParser creates a new parser.  pkg is the package to write to.
-func NewParser(pkg string) *Parser {
-	return &Parser{
-		pkg: pkg,
+func NewParser(pkg string, options ...ParserOption) *Parser {
+	p := &Parser{
+		pkg:          pkg,
+		thriftImport: DefaultThriftImport,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// ParserOption configures a Parser.
+type ParserOption func(*Parser)
+
+// PackagePrefixOption prefixes the import paths of thrift gen packages with prefix, e.g. the
+// module they are generated into, like the package_prefix option of the thrift go generator.
+func PackagePrefixOption(prefix string) ParserOption {
+	return func(p *Parser) {
+		if prefix != "" {
+			p.packagePrefix = strings.TrimSuffix(prefix, "/") + "/"
+		}
+	}
+}
+
+// ThriftImportOption sets the import path of the thrift go library, like the thrift_import option
+// of the thrift go generator.
+func ThriftImportOption(thriftImport string) ParserOption {
+	return func(p *Parser) {
+		p.thriftImport = thriftImport
 	}
 }
 
@@ -79,50 +224,360 @@ func (p *Parser) Parse(thriftFile string) (*Thrift, error) {
 	if err != nil {
 		return nil, err
 	}
-	p.imports = map[string]bool{p.absPathToImport(p.mainFile): true} // clean imports for next time
+	p.clearTables()
+	return p.parseFile(p.mainFile, p.pkg)
+}
+
+// ParseAll parses the given thrift file and all the files it includes, directly or not, returning
+// a Thrift for each file declaring services. Each Thrift is written to the package named after the
+// last element of its file's go namespace, rather than to the parser's package.
+func (p *Parser) ParseAll(thriftFile string) ([]*Thrift, error) {
+	var err error
+	p.thrift, _, err = parser.New().ParseFile(thriftFile)
+	if err != nil {
+		return nil, err
+	}
+	p.clearTables()
+	return p.parseAll()
+}
+
+// parseAll parses the services of all files in p.thrift, returning an ErrorList of the problems
+// found in all of them if there are any.
+func (p *Parser) parseAll() ([]*Thrift, error) {
+	files := make([]string, 0, len(p.thrift))
+	for file := range p.thrift {
+		files = append(files, file)
+	}
+	thrifts, errs := p.parseFiles(files)
+	if len(errs) > 0 {
+		return nil, ErrorList(errs)
+	}
+	return thrifts, nil
+}
+
+// ParseBatch parses the given thrift files, which must be absolute paths, returning a Thrift for
+// each file declaring services like ParseAll, and an error for each file that could not be parsed.
+// Files included by several of the given files are parsed once.
+func (p *Parser) ParseBatch(thriftFiles []string) ([]*Thrift, []error) {
+	p.thrift = map[string]*parser.Thrift{}
+	p.clearTables()
+	errs := []error{}
+	parsed := make([]string, 0, len(thriftFiles))
+	for _, file := range thriftFiles {
+		if _, ok := p.thrift[file]; !ok {
+			thrift, _, err := parser.New().ParseFile(file)
+			if err != nil {
+				errs = append(errs, &FileError{File: file, Err: err})
+				continue
+			}
+			for included, includedThrift := range thrift {
+				if _, ok := p.thrift[included]; !ok {
+					p.thrift[included] = includedThrift
+				}
+			}
+		}
+		parsed = append(parsed, file)
+	}
+	thrifts, parseErrs := p.parseFiles(parsed)
+	return thrifts, append(errs, parseErrs...)
+}
+
+// parseFiles parses the services of files, in order of their paths, skipping those without services.
+// Each Thrift is written to the package named after the last element of its file's go namespace.
+// The problems found in each file are returned one by one.
+func (p *Parser) parseFiles(files []string) ([]*Thrift, []error) {
+	sort.Strings(files)
+	thrifts := make([]*Thrift, 0, len(files))
+	errs := []error{}
+	for _, file := range files {
+		if len(p.thrift[file].Services) == 0 {
+			continue
+		}
+		p.mainFile = file
+		thrift, err := p.parseFile(file, path.Base(p.absPathToImport(file)))
+		if err != nil {
+			errs = append(errs, err.(ErrorList)...)
+			continue
+		}
+		thrifts = append(thrifts, thrift)
+	}
+	return thrifts, errs
+}
+
+// parseFile parses the services of file, which must be p.mainFile, into a Thrift written to pkg. It
+// returns an ErrorList of all the problems found if there are any.
+func (p *Parser) parseFile(file, pkg string) (*Thrift, error) {
+	p.imports = map[string]bool{p.absPathToImport(file): true} // clean imports for next time
+	docs := serviceDocs(p.source(file))
 	services := []*Service{}
-	for _, service := range p.thrift[p.mainFile].Services {
-		services = append(services, p.parseService(service))
+	for _, service := range p.thrift[file].Services {
+		parsed := p.parseService(service)
+		parsed.Doc = docs[service.Name]
+		services = append(services, parsed)
+	}
+	if errs := p.takeErrors(); len(errs) > 0 {
+		return nil, errs
 	}
 	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
 	imports := p.getUsedImports()
+	sanitizeArgs(services, imports)
 	return &Thrift{
-		Package:       p.pkg,
+		File:          file,
+		Package:       pkg,
 		Services:      services,
-		ThriftImport:  p.absPathToImport(p.mainFile),
-		ThriftPackage: p.absPathToPkg(p.mainFile),
+		ThriftImport:  p.absPathToImport(file),
+		ThriftPackage: p.absPathToPkg(file),
 		Imports:       imports,
+		LibraryImport: p.thriftImport,
 	}, nil
 }
 
 func (p *Parser) parseService(service *parser.Service) *Service {
 	//TODO(mdee) handle extends.
+	p.service = service.Name
+	defer func() { p.service = "" }()
 	methods := make([]*Method, 0, len(service.Methods))
 	for _, method := range service.Methods {
-		methods = append(methods, p.parseMethod(method))
+		methods = append(methods, p.parseMethod(service, method))
 	}
 	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
-	return &Service{Name: titleCase(service.Name), Methods: methods}
+	return &Service{
+		Name:        publicize(service.Name),
+		ThriftName:  service.Name,
+		Methods:     methods,
+		Annotations: annotationMap(service.Annotations),
+	}
 }
 
-func (p *Parser) parseMethod(method *parser.Method) *Method {
+func (p *Parser) parseMethod(service *parser.Service, method *parser.Method) *Method {
+	p.service, p.method = service.Name, method.Name
+	defer func() { p.method = "" }()
 	returnType := ""
 	ret := method.ReturnType
 	if ret != nil {
 		returnType = p.parseType(ret)
 	}
+	doc, argDocs := paramDocs(docComment(method.Comment))
 	args := make([]*Arg, len(method.Arguments))
+	sensitive := []string{}
+	required := []string{}
 	for i, arg := range method.Arguments {
 		typeName := p.parseType(arg.Type)
-		args[i] = &Arg{Name: arg.Name, Type: typeName}
+		args[i] = &Arg{
+			Name:      arg.Name,
+			Type:      typeName,
+			FieldName: publicize(arg.Name),
+			Doc:       argDocs[arg.Name],
+			Struct:    p.parseArgStruct(arg.Type, typeName),
+		}
+		required = append(required, p.requiredFields(p.mainFile, arg.Type, arg.Name, map[*parser.Struct]bool{})...)
+		if isSensitive(arg.Annotations) {
+			sensitive = append(sensitive, arg.Name)
+		} else {
+			sensitive = append(sensitive, p.sensitiveFields(p.mainFile, arg.Type, arg.Name, map[*parser.Struct]bool{})...)
+		}
+	}
+
+	cacheTTL := time.Duration(0)
+	if value, ok := annotationValue(method.Annotations, "cache_ttl"); ok {
+		var err error
+		cacheTTL, err = time.ParseDuration(value)
+		if err != nil || cacheTTL <= 0 {
+			p.errorf("cache_ttl", "invalid cache_ttl %q", value)
+			cacheTTL = 0
+		}
+	}
+
+	// exceptions are not part of the generated signatures, so their packages are not imported
+	imports := p.imports
+	p.imports = map[string]bool{}
+	exceptions := make([]*Arg, len(method.Exceptions))
+	for i, exception := range method.Exceptions {
+		exceptions[i] = &Arg{Name: exception.Name, Type: p.parseType(exception.Type), FieldName: publicize(exception.Name)}
+	}
+	p.imports = imports
+
+	return &Method{
+		Name:         publicize(method.Name),
+		ThriftName:   method.Name,
+		ArgsStruct:   argsStructName(service.Name, method.Name),
+		ResponseType: returnType,
+		Idempotent:   annotationIsTrue(method.Annotations, "idempotent"),
+		ReadOnly:     annotationIsTrue(method.Annotations, "read_only"),
+		CacheTTL:     cacheTTL,
+		Request:      args,
+		Sensitive:    sensitive,
+		Required:     required,
+		Exceptions:   exceptions,
+		Annotations:  annotationMap(method.Annotations),
+		Doc:          doc,
+	}
+}
+
+// isSensitive returns whether annotations mark a field as sensitive.
+func isSensitive(annotations []*parser.Annotation) bool {
+	return annotationIsTrue(annotations, "sensitive")
+}
+
+// annotationIsTrue returns whether annotations set name="true".
+func annotationIsTrue(annotations []*parser.Annotation, name string) bool {
+	value, _ := annotationValue(annotations, name)
+	return value == "true"
+}
+
+// annotationMap returns annotations by name, or nil if there are none.
+func annotationMap(annotations []*parser.Annotation) map[string]string {
+	if len(annotations) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(annotations))
+	for _, annotation := range annotations {
+		values[annotation.Name] = annotation.Value
+	}
+	return values
+}
+
+// paramDocs splits the @param tags, e.g. "@param id the user's id", out of doc, returning the rest
+// of doc and the docs of the params by name.
+func paramDocs(doc string) (string, map[string]string) {
+	params := map[string]string{}
+	kept := []string{}
+	param := ""
+	for _, line := range strings.Split(doc, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "@param":
+			param = fields[1]
+			params[param] = strings.Join(fields[2:], " ")
+		case param != "" && len(fields) > 0 && !strings.HasPrefix(fields[0], "@"):
+			params[param] = strings.TrimSpace(params[param] + " " + strings.Join(fields, " "))
+		default:
+			param = ""
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), params
+}
+
+// serviceDocs returns the doc comments of the services declared in src by name. The thrift parser
+// only keeps the comments of methods, so they are read from the source.
+func serviceDocs(src *source) map[string]string {
+	docs := map[string]string{}
+	for i, line := range src.code {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "service" {
+			continue
+		}
+		name := strings.TrimRight(fields[1], "{")
+		start := i
+		for start > 0 && src.isComment(start-1) {
+			start--
+		}
+		if doc := docComment(strings.Join(src.lines[start:i], "\n")); doc != "" {
+			docs[name] = doc
+		}
+	}
+	return docs
+}
+
+// docComment returns the text of a thrift comment, without its comment markers.
+func docComment(comment string) string {
+	comment = strings.TrimSpace(comment)
+	comment = strings.TrimPrefix(comment, "/**")
+	comment = strings.TrimPrefix(comment, "/*")
+	comment = strings.TrimSuffix(comment, "*/")
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		for _, marker := range []string{"*", "//", "#"} {
+			if strings.HasPrefix(line, marker) {
+				line = strings.TrimSpace(strings.TrimPrefix(line, marker))
+				break
+			}
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// annotationValue returns the value annotations set name to, if any.
+func annotationValue(annotations []*parser.Annotation, name string) (string, bool) {
+	for _, annotation := range annotations {
+		if annotation.Name == name {
+			return annotation.Value, true
+		}
+	}
+	return "", false
+}
+
+// sensitiveFields returns the paths of the fields annotated as sensitive within a value of
+// parserType, which is referenced from file. Paths are prefixed with path. visiting holds the structs
+// currently being walked, to stop at recursive types.
+func (p *Parser) sensitiveFields(file string, parserType *parser.Type, path string,
+	visiting map[*parser.Struct]bool) []string {
+	if parserType.ValueType != nil {
+		paths := p.sensitiveFields(file, parserType.ValueType, path, visiting)
+		if parserType.KeyType != nil {
+			paths = append(paths, p.sensitiveFields(file, parserType.KeyType, path, visiting)...)
+		}
+		return paths
+	}
+
+	typeFile, name := p.resolveName(file, parserType.Name)
+	thrift := p.thrift[typeFile]
+	if thrift == nil {
+		return nil
+	}
+	if typedef, ok := thrift.Typedefs[name]; ok {
+		return p.sensitiveFields(typeFile, typedef.Type, path, visiting)
+	}
+	structType := findStruct(thrift, name)
+	if structType == nil || visiting[structType] {
+		return nil
+	}
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	paths := []string{}
+	for _, field := range structType.Fields {
+		fieldPath := path + "." + field.Name
+		if isSensitive(field.Annotations) {
+			paths = append(paths, fieldPath)
+		} else {
+			paths = append(paths, p.sensitiveFields(typeFile, field.Type, fieldPath, visiting)...)
+		}
+	}
+	return paths
+}
+
+// resolveName converts a type name referenced from file into the file it is declared in and its
+// name within that file. The file is empty if the include is unknown.
+func (p *Parser) resolveName(file, typeName string) (string, string) {
+	split := strings.SplitN(typeName, ".", 2)
+	if len(split) != 2 {
+		return file, typeName
 	}
+	return p.thrift[file].Includes[split[0]], split[1]
+}
 
-	return &Method{Name: titleCase(method.Name), ResponseType: returnType, Request: args}
+// findStruct returns the struct, union or exception named name in thrift, if any.
+func findStruct(thrift *parser.Thrift, name string) *parser.Struct {
+	for _, structs := range []map[string]*parser.Struct{thrift.Structs, thrift.Unions, thrift.Exceptions} {
+		if structType, ok := structs[name]; ok {
+			return structType
+		}
+	}
+	return nil
 }
 
 // absPathToImport converts an absolute path into the go import path for that file.
+// Like the thrift go generator, files without a go namespace are imported by their lower-cased name.
 func (p *Parser) absPathToImport(path string) string {
-	return strings.Replace(p.thrift[path].Namespaces["go"], ".", "/", -1)
+	namespace := p.thrift[path].Namespaces["go"]
+	if namespace == "" {
+		namespace = strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".thrift"))
+	}
+	return p.packagePrefix + strings.Replace(namespace, ".", "/", -1)
 }
 
 // absPathToPkg converts an absolute path into the go package for that file.
@@ -132,22 +587,6 @@ func (p *Parser) absPathToPkg(path string) string {
 	return imported[strings.LastIndex(p.absPathToImport(path), "/")+1:]
 }
 
-// typeToAbsPath converts a thrift type into the absolute path of the file it came from.
-func (p *Parser) typeToAbsPath(typeName string) string {
-	split := strings.Split(typeName, ".")
-	typeFile := p.mainFile
-	if len(split) == 2 {
-		typeFile = p.thrift[p.mainFile].Includes[split[0]]
-	}
-	return typeFile
-}
-
-// typeToPackage converts a type into the go package that it is imported with.
-func (p *Parser) typeToPackage(typeName string) string {
-	imported := p.absPathToPkg(p.typeToAbsPath(typeName))
-	return imported
-}
-
 // getUsedImports returns the imports needed for all the types in service signatures.
 func (p *Parser) getUsedImports() []string {
 	imports := make([]string, 0, len(p.imports))
@@ -171,7 +610,8 @@ func (p *Parser) parseType(parserType *parser.Type) string {
 		case "set":
 			return fmt.Sprintf("map[%s]bool", p.parseType(parserType.ValueType))
 		default:
-			panic("unknown type name")
+			p.errorf(parserType.Name, "unknown container type %s", parserType.Name)
+			return "interface{}"
 		}
 	} else {
 		name := p.parseName(parserType.Name)
@@ -188,103 +628,26 @@ var (
 		"i64":    "int64",
 		"double": "float64",
 		"byte":   "int8",
+		"i8":     "int8",
 		"binary": "[]byte",
 	}
 )
 
-// parseName converts a thrift base type name into its go equivalent.
-// TODO handle enums, optional
+// parseName converts a thrift base type or declared type name into its go equivalent: structs,
+// unions and exceptions are pointers, enums and typedefs are values.
 func (p *Parser) parseName(typeName string) string {
 	if val, ok := primitiveTypes[typeName]; ok {
 		return val
 	}
-	if strings.Contains(typeName, ".") {
-		split := strings.Split(typeName, ".")
-		ns := p.typeToPackage(typeName)
-
-		return fmt.Sprintf("*%s.%s", ns, titleCase(split[1]))
+	typeFile, name, kind, ok := p.lookupType(p.mainFile, typeName)
+	if !ok {
+		return "interface{}"
 	}
-	return fmt.Sprintf("*%s.%s", p.typeToPackage(typeName), titleCase(typeName))
-}
-
-// titleCase converts a name into UpperCamelCase. It takes into account a subset of edge cases from
-// https://github.com/apache/thrift/blob/master/compiler/cpp/src/thrift/generate/t_go_generator.cc.
-// THIS IS OVERSIMPLIFIED AND WILL FAIL FOR CERTAIN EDGE CASES
-func titleCase(s string) string {
-	prev := '_'
-	s = fixForInitialismCase(s)
-	titleCased := strings.Map(
-		func(r rune) rune {
-			if r == '_' {
-				prev = r
-				return -1
-			}
-			if prev == '_' {
-				prev = r
-				return unicode.ToUpper(r)
-			}
-			prev = r
-			return r
-		}, s)
-	// special cases when a struct name ends in 'Args'/'Result', leads to go name having '_' appended
-	// https://github.com/apache/thrift/blob/master/compiler/cpp/src/thrift/generate/t_go_generator.cc#L495
-	if (len(titleCased) >= 4 && titleCased[len(titleCased)-4:] == "Args") ||
-		(len(titleCased) >= 6 && s[len(titleCased)-6:] == "Result") {
-		titleCased = titleCased + "_"
-	}
-	return titleCased
-}
-
-// lifted from https://github.com/apache/thrift/blob/master/compiler/cpp/src/thrift/generate/t_go_generator.cc#L671
-var initialismList = []string{
-	"api",
-	"ascii",
-	"cpu",
-	"css",
-	"dns",
-	"eof",
-	"guid",
-	"html",
-	"http",
-	"https",
-	"id",
-	"ip",
-	"json",
-	"lhs",
-	"qps",
-	"ram",
-	"rhs",
-	"rpc",
-	"sla",
-	"smtp",
-	"ssh",
-	"tcp",
-	"tls",
-	"ttl",
-	"udp",
-	"ui",
-	"uid",
-	"uuid",
-	"uri",
-	"url",
-	"utf8",
-	"vm",
-	"xml",
-	"xsrf",
-	"xss",
-}
-
-// fixForInitialismCase handles cases in the supplied string where snakecase initialism is given and uppercases it
-// as the go gen will do https://github.com/apache/thrift/blob/master/compiler/cpp/src/thrift/generate/t_go_generator.cc#L449
-func fixForInitialismCase(s string) string {
-	for _, initialism := range initialismList {
-		parts := strings.Split(s, "_")
-		for i, part := range parts {
-			if part == initialism {
-				parts[i] = strings.ToUpper(initialism)
-			}
-		}
-		s = strings.Join(parts, "_")
+	goName := p.absPathToPkg(typeFile) + "." + publicize(name)
+	switch kind {
+	case symbolStruct, symbolUnion, symbolException:
+		return "*" + goName
+	default:
+		return goName
 	}
-	return s
 }
@@ -1,34 +1,295 @@
 package gen
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
 
-func TestTitleCases(t *testing.T) {
-	titleCases := []struct {
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+func TestSensitiveFields(t *testing.T) {
+	sensitive := []*parser.Annotation{{Name: "sensitive", Value: "true"}}
+	p := &Parser{
+		mainFile: "/main.thrift",
+		imports:  map[string]bool{},
+		thrift: map[string]*parser.Thrift{
+			"/main.thrift": {
+				Includes: map[string]string{"shared": "/shared.thrift"},
+				Typedefs: map[string]*parser.Typedef{
+					"Accounts": {Type: &parser.Type{Name: "list", ValueType: &parser.Type{Name: "shared.Account"}}},
+				},
+				Structs: map[string]*parser.Struct{
+					"Request": {Fields: []*parser.Field{
+						{Name: "accounts", Type: &parser.Type{Name: "Accounts"}},
+						{Name: "parent", Type: &parser.Type{Name: "Request"}},
+						{Name: "note", Type: &parser.Type{Name: "string"}},
+					}},
+				},
+			},
+			"/shared.thrift": {
+				Structs: map[string]*parser.Struct{
+					"Account": {Fields: []*parser.Field{
+						{Name: "id", Type: &parser.Type{Name: "i64"}},
+						{Name: "ssn", Type: &parser.Type{Name: "string"}, Annotations: sensitive},
+					}},
+				},
+			},
+		},
+	}
+
+	method := p.parseMethod(&parser.Service{Name: "AccountService"}, &parser.Method{
+		Name: "update",
+		Arguments: []*parser.Field{
+			{Name: "request", Type: &parser.Type{Name: "Request"}},
+			{Name: "password", Type: &parser.Type{Name: "string"}, Annotations: sensitive},
+		},
+	})
+
+	expected := []string{"request.accounts.ssn", "password"}
+	if !reflect.DeepEqual(method.Sensitive, expected) {
+		t.Errorf("Sensitive => %v, want %v", method.Sensitive, expected)
+	}
+	if method.ArgsStruct != "AccountServiceUpdateArgs" {
+		t.Errorf("ArgsStruct => %q, want %q", method.ArgsStruct, "AccountServiceUpdateArgs")
+	}
+}
+
+func TestAnnotationIsTrue(t *testing.T) {
+	annotations := []*parser.Annotation{{Name: "idempotent", Value: "true"}, {Name: "sensitive", Value: "false"}}
+	if !annotationIsTrue(annotations, "idempotent") {
+		t.Error("expected idempotent to be true")
+	}
+	if annotationIsTrue(annotations, "sensitive") || annotationIsTrue(annotations, "missing") {
+		t.Error("expected only annotations set to \"true\" to be true")
+	}
+}
+
+func TestCacheTTLExpr(t *testing.T) {
+	cacheTTLs := []struct {
+		in       time.Duration
+		expected string
+	}{
+		{30 * time.Second, "30 * time.Second"},
+		{90 * time.Minute, "90 * time.Minute"},
+		{2 * time.Hour, "2 * time.Hour"},
+		{1500 * time.Millisecond, "1500 * time.Millisecond"},
+		{10, "10 * time.Nanosecond"},
+	}
+
+	for _, tc := range cacheTTLs {
+		actual := (&Method{CacheTTL: tc.in}).CacheTTLExpr()
+		if actual != tc.expected {
+			t.Errorf("CacheTTLExpr(%v) => %q, want %q", tc.in, actual, tc.expected)
+		}
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	p := &Parser{
+		thrift: map[string]*parser.Thrift{
+			"/main.thrift": {
+				Includes:   map[string]string{"users": "/users.thrift", "types": "/types.thrift"},
+				Namespaces: map[string]string{"go": "example.main"},
+				Services:   map[string]*parser.Service{"main_service": {Name: "main_service"}},
+			},
+			"/users.thrift": {
+				Includes:   map[string]string{"types": "/types.thrift"},
+				Namespaces: map[string]string{"go": "example.users"},
+				Services: map[string]*parser.Service{"UserService": {Name: "UserService", Methods: map[string]*parser.Method{
+					"get": {Name: "get", ReturnType: &parser.Type{Name: "types.User"}},
+				}}},
+			},
+			"/types.thrift": {
+				Namespaces: map[string]string{"go": "example.types"},
+				Structs:    map[string]*parser.Struct{"User": {Name: "User"}},
+			},
+		},
+	}
+
+	thrifts, err := p.parseAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(thrifts) != 2 {
+		t.Fatalf("expected a Thrift per file declaring services, received %d", len(thrifts))
+	}
+	main, users := thrifts[0], thrifts[1]
+	if main.Package != "main" || main.ThriftImport != "example/main" || main.Services[0].Name != "MainService" {
+		t.Errorf("unexpected Thrift for /main.thrift: %+v", main)
+	}
+	if users.Package != "users" || users.ThriftImport != "example/users" || users.File != "/users.thrift" {
+		t.Errorf("unexpected Thrift for /users.thrift: %+v", users)
+	}
+	if responseType := users.Services[0].Methods[0].ResponseType; responseType != "*types.User" {
+		t.Errorf("expected types to resolve from /users.thrift, received %q", responseType)
+	}
+	expectedImports := []string{"example/types", "example/users"}
+	if !reflect.DeepEqual(users.Imports, expectedImports) {
+		t.Errorf("Imports => %v, want %v", users.Imports, expectedImports)
+	}
+
+	// files without a go namespace are imported by their name
+	p.thrift["/types.thrift"].Services = p.thrift["/main.thrift"].Services
+	p.thrift["/types.thrift"].Namespaces = nil
+	thrifts, err = p.parseAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if types := thrifts[1]; types.File != "/types.thrift" || types.ThriftImport != "types" || types.Package != "types" {
+		t.Errorf("unexpected Thrift for /types.thrift: %+v", types)
+	}
+
+	// broken files are reported without stopping the others
+	p.thrift["/users.thrift"].Services["UserService"].Methods["get"].ReturnType = &parser.Type{
+		Name: "tuple", ValueType: &parser.Type{Name: "i32"},
+	}
+	thrifts, errs := p.parseFiles([]string{"/main.thrift", "/users.thrift", "/types.thrift"})
+	if len(thrifts) != 2 || thrifts[0].File != "/main.thrift" || thrifts[1].File != "/types.thrift" {
+		t.Errorf("expected /main.thrift and /types.thrift to be parsed, received %v", thrifts)
+	}
+	if len(errs) != 1 || errs[0].Error() != "/users.thrift: UserService.get: unknown container type tuple" {
+		t.Errorf("expected an error for /users.thrift, received %v", errs)
+	}
+}
+
+func TestPackagePrefix(t *testing.T) {
+	p := NewParser("", PackagePrefixOption("github.com/example/gen-go"), ThriftImportOption("github.com/apache/thrift/lib/go/thrift"))
+	p.thrift = map[string]*parser.Thrift{
+		"/main.thrift": {
+			Includes:   map[string]string{"shared": "/Shared.thrift"},
+			Namespaces: map[string]string{"go": "example.main"},
+			Services: map[string]*parser.Service{"Main": {Name: "Main", Methods: map[string]*parser.Method{
+				"get": {Name: "get", ReturnType: &parser.Type{Name: "shared.Thing"}},
+			}}},
+		},
+		"/Shared.thrift": {Structs: map[string]*parser.Struct{"Thing": {Name: "Thing"}}},
+	}
+
+	thrifts, err := p.parseAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	main := thrifts[0]
+	if main.ThriftImport != "github.com/example/gen-go/example/main" || main.Package != "main" {
+		t.Errorf("unexpected Thrift for /main.thrift: %+v", main)
+	}
+	expectedImports := []string{"github.com/example/gen-go/example/main", "github.com/example/gen-go/shared"}
+	if !reflect.DeepEqual(main.Imports, expectedImports) {
+		t.Errorf("Imports => %v, want %v", main.Imports, expectedImports)
+	}
+	if responseType := main.Services[0].Methods[0].ResponseType; responseType != "*shared.Thing" {
+		t.Errorf("expected the included type to resolve, received %q", responseType)
+	}
+	if main.LibraryImport != "github.com/apache/thrift/lib/go/thrift" {
+		t.Errorf("LibraryImport => %q", main.LibraryImport)
+	}
+}
+
+func TestParseMethodModel(t *testing.T) {
+	p := &Parser{
+		mainFile: "/main.thrift",
+		imports:  map[string]bool{},
+		thrift: map[string]*parser.Thrift{
+			"/main.thrift": {
+				Includes:   map[string]string{"errors": "/errors.thrift"},
+				Namespaces: map[string]string{"go": "example.main"},
+			},
+			"/errors.thrift": {
+				Namespaces: map[string]string{"go": "example.errors"},
+				Exceptions: map[string]*parser.Struct{"NotFound": {Name: "NotFound"}},
+			},
+		},
+	}
+
+	method := p.parseMethod(&parser.Service{Name: "Example"}, &parser.Method{
+		Name:        "get",
+		Comment:     "// Gets a thing.",
+		ReturnType:  &parser.Type{Name: "string"},
+		Exceptions:  []*parser.Field{{Name: "missing", Type: &parser.Type{Name: "errors.NotFound"}}},
+		Annotations: []*parser.Annotation{{Name: "owner", Value: "search"}},
+	})
+
+	expectedExceptions := []*Arg{{Name: "missing", Type: "*errors.NotFound", FieldName: "Missing"}}
+	if !reflect.DeepEqual(method.Exceptions, expectedExceptions) {
+		t.Errorf("Exceptions => %v, want %v", method.Exceptions, expectedExceptions)
+	}
+	if len(p.imports) != 0 {
+		t.Errorf("expected exceptions not to be imported, received %v", p.imports)
+	}
+	if !reflect.DeepEqual(method.Annotations, map[string]string{"owner": "search"}) {
+		t.Errorf("Annotations => %v", method.Annotations)
+	}
+	if method.Doc != "Gets a thing." {
+		t.Errorf("Doc => %q, want %q", method.Doc, "Gets a thing.")
+	}
+}
+
+func TestParamDocs(t *testing.T) {
+	doc, params := paramDocs("Gets a user.\n\n@param id the id\n  of the user\n@param verbose\n@return the user")
+	if doc != "Gets a user.\n\n@return the user" {
+		t.Errorf("expected @param tags to be removed, received %q", doc)
+	}
+	expected := map[string]string{"id": "the id of the user", "verbose": ""}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("params => %v, want %v", params, expected)
+	}
+}
+
+func TestArgDocs(t *testing.T) {
+	method := &Method{Request: []*Arg{
+		{Name: "type", GoName: "type_a1", Doc: "the type\nof the thing"},
+		{Name: "id"},
+		{Name: "len", GoName: "len_", Doc: "the length"},
+	}}
+	expected := "  - type_a1: the type\n    of the thing\n  - len_: the length"
+	if docs := method.ArgDocs(); docs != expected {
+		t.Errorf("ArgDocs() => %q, want %q", docs, expected)
+	}
+}
+
+func TestServiceDocs(t *testing.T) {
+	src := newSource(`namespace go example
+
+/**
+ * Users stores users.
+ */
+service Users {
+}
+
+struct Undocumented {}
+service Undocumented{
+}
+
+// Groups stores groups
+// of users.
+service Groups extends Users {}
+
+/* service Commented {} */
+const string s = "service Quoted {}"
+`)
+
+	expected := map[string]string{"Users": "Users stores users.", "Groups": "Groups stores groups\nof users."}
+	if docs := serviceDocs(src); !reflect.DeepEqual(docs, expected) {
+		t.Errorf("serviceDocs => %v, want %v", docs, expected)
+	}
+}
+
+func TestDocComment(t *testing.T) {
+	docComments := []struct {
 		in       string
 		expected string
 	}{
-		// simple cases
-		{"t", "T"},
-		{"baseCase", "BaseCase"},
-		// check snaking coverage
-		{"snake_case", "SnakeCase"},
-		// check initialisms
-		{"snake_json", "SnakeJSON"},
-		{"json_snake", "JSONSnake"},
-		{"s_json_snake", "SJSONSnake"},
-		// initialisms strict on casing
-		{"jSon_snake", "JSonSnake"},
-		// key word edge cases
-		{"Args", "Args_"},
-		{"AResult", "AResult_"},
-		// combo snake, initialism, key word
-		{"json_args", "JSONArgs_"},
-	}
-
-	for _, tc := range titleCases {
-		actual := titleCase(tc.in)
+		{"", ""},
+		{"// Gets a thing.", "Gets a thing."},
+		{"/**\n * Gets a thing.\n *\n * Or fails.\n */", "Gets a thing.\n\nOr fails."},
+		{"# Gets a thing.", "Gets a thing."},
+	}
+
+	for _, tc := range docComments {
+		actual := docComment(tc.in)
 		if actual != tc.expected {
-			t.Errorf("titleCase(%q) => %q, want %q", tc.in, actual, tc.expected)
+			t.Errorf("docComment(%q) => %q, want %q", tc.in, actual, tc.expected)
 		}
 	}
 }
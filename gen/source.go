@@ -0,0 +1,81 @@
+package gen
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// source is a thrift file as read for what the thrift parser does not keep: the doc comments of
+// services, which fields are required and the positions of declarations.
+type source struct {
+	lines []string // The lines of the file.
+	code  []string // The lines with comments and the contents of strings blanked, keeping columns.
+}
+
+// newSource returns the source of a thrift file with contents text.
+func newSource(text string) *source {
+	return &source{
+		lines: strings.Split(text, "\n"),
+		code:  strings.Split(stripComments(text), "\n"),
+	}
+}
+
+// source returns the source of file, read once per file, or an empty source if it can't be read.
+func (p *Parser) source(file string) *source {
+	if p.sources == nil {
+		p.sources = map[string]*source{}
+	}
+	if _, ok := p.sources[file]; !ok {
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			text = nil
+		}
+		p.sources[file] = newSource(string(text))
+	}
+	return p.sources[file]
+}
+
+// isComment returns whether line i only holds comments, and is not blank.
+func (s *source) isComment(i int) bool {
+	return strings.TrimSpace(s.code[i]) == "" && strings.TrimSpace(s.lines[i]) != ""
+}
+
+// stripComments replaces the //, # and /* */ comments of text, and the contents of its string
+// literals, with spaces, keeping line breaks, so that what is left is code at its original
+// position.
+func stripComments(text string) string {
+	stripped := []byte(text)
+	blank := func(i int) {
+		if stripped[i] != '\n' {
+			stripped[i] = ' '
+		}
+	}
+	for i := 0; i < len(stripped); i++ {
+		switch c := stripped[i]; {
+		case c == '#' || c == '/' && i+1 < len(stripped) && stripped[i+1] == '/':
+			for ; i < len(stripped) && stripped[i] != '\n'; i++ {
+				blank(i)
+			}
+		case c == '/' && i+1 < len(stripped) && stripped[i+1] == '*':
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				end = len(text)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				blank(i)
+			}
+			i--
+		case c == '"' || c == '\'':
+			for i++; i < len(stripped) && stripped[i] != c && stripped[i] != '\n'; i++ {
+				if stripped[i] == '\\' && i+1 < len(stripped) && stripped[i+1] != '\n' {
+					blank(i)
+					i++
+				}
+				blank(i)
+			}
+		}
+	}
+	return string(stripped)
+}
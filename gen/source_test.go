@@ -0,0 +1,36 @@
+package gen
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	strippings := []struct {
+		in       string
+		expected string
+	}{
+		{"struct A {}", "struct A {}"},
+		{"1: i32 a // a\n2: i32 b", "1: i32 a     \n2: i32 b"},
+		{"# a\n1: i32 a", "   \n1: i32 a"},
+		{"/* a */ 1: required i32 a", "        1: required i32 a"},
+		{"/** a\n * b */ service A", "     \n        service A"},
+		{"/* unterminated\nservice A", "               \n         "},
+		{`1: string a = "// {";`, `1: string a = "    ";`},
+		{`(note = 'say "hi"')`, `(note = '        ')`},
+		{`"a\"b" c`, `"    " c`},
+	}
+
+	for _, tc := range strippings {
+		if actual := stripComments(tc.in); actual != tc.expected {
+			t.Errorf("stripComments(%q) => %q, want %q", tc.in, actual, tc.expected)
+		}
+	}
+}
+
+func TestSourceIsComment(t *testing.T) {
+	src := newSource("// a\n\nstruct A {} // a\n/*\n  a\n*/")
+	expected := []bool{true, false, false, true, true, true}
+	for i, comment := range expected {
+		if src.isComment(i) != comment {
+			t.Errorf("isComment(%d) => %v, want %v", i, !comment, comment)
+		}
+	}
+}
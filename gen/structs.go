@@ -0,0 +1,186 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+// Struct is a thrift struct passed as a method arg.
+type Struct struct {
+	ThriftName  string   `json:"thriftName"`  // The struct name as declared in the thrift file.
+	Type        string   `json:"type"`        // The go type of the struct, e.g. *users.User.
+	Constructor string   `json:"constructor"` // The thrift gen func returning a new struct with its defaults, e.g. users.NewUser.
+	Fields      []*Field `json:"fields"`
+}
+
+// Field is a field of a thrift struct.
+type Field struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	FieldName string `json:"fieldName"` // The name of the field in the thrift gen struct.
+	Required  bool   `json:"required,omitempty"`
+	Optional  bool   `json:"optional,omitempty"`
+	Default   string `json:"default,omitempty"` // The default value as written in thrift, if any.
+}
+
+// Defaults returns the fields with default values.
+func (s *Struct) Defaults() []*Field {
+	fields := []*Field{}
+	for _, field := range s.Fields {
+		if field.Default != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// parseArgStruct returns the Struct of an arg of goType, or nil if the arg is not a struct. Structs
+// referenced through typedefs, unions and exceptions are not included, as the thrift generator
+// gives them no constructor.
+func (p *Parser) parseArgStruct(parserType *parser.Type, goType string) *Struct {
+	if parserType.ValueType != nil {
+		return nil
+	}
+	typeFile, name := p.resolveName(p.mainFile, parserType.Name)
+	thrift := p.thrift[typeFile]
+	if thrift == nil {
+		return nil
+	}
+	structType, ok := thrift.Structs[name]
+	if !ok {
+		return nil
+	}
+	required := p.requiredFieldIDs(typeFile)[name]
+	fields := make([]*Field, len(structType.Fields))
+	for i, field := range structType.Fields {
+		fields[i] = &Field{
+			ID:        field.ID,
+			Name:      field.Name,
+			FieldName: publicize(field.Name),
+			Required:  required[field.ID],
+			Optional:  field.Optional,
+			Default:   formatDefault(field.Default),
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	return &Struct{
+		ThriftName:  name,
+		Type:        goType,
+		Constructor: strings.Replace(strings.TrimPrefix(goType, "*"), ".", ".New", 1),
+		Fields:      fields,
+	}
+}
+
+// requiredFields returns the paths of the required fields within a value of parserType, at path,
+// like sensitiveFields.
+func (p *Parser) requiredFields(file string, parserType *parser.Type, path string,
+	visiting map[*parser.Struct]bool) []string {
+	if parserType.ValueType != nil {
+		paths := p.requiredFields(file, parserType.ValueType, path, visiting)
+		if parserType.KeyType != nil {
+			paths = append(paths, p.requiredFields(file, parserType.KeyType, path, visiting)...)
+		}
+		return paths
+	}
+
+	typeFile, name := p.resolveName(file, parserType.Name)
+	thrift := p.thrift[typeFile]
+	if thrift == nil {
+		return nil
+	}
+	if typedef, ok := thrift.Typedefs[name]; ok {
+		return p.requiredFields(typeFile, typedef.Type, path, visiting)
+	}
+	structType := findStruct(thrift, name)
+	if structType == nil || visiting[structType] {
+		return nil
+	}
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	required := p.requiredFieldIDs(typeFile)[name]
+	paths := []string{}
+	for _, field := range structType.Fields {
+		fieldPath := path + "." + field.Name
+		if required[field.ID] {
+			paths = append(paths, fieldPath)
+		}
+		paths = append(paths, p.requiredFields(typeFile, field.Type, fieldPath, visiting)...)
+	}
+	return paths
+}
+
+// requiredFieldIDs returns the ids of the required fields of the structs declared in file, by
+// struct name, reading them once per file.
+func (p *Parser) requiredFieldIDs(file string) map[string]map[int]bool {
+	if p.required == nil {
+		p.required = map[string]map[string]map[int]bool{}
+	}
+	if _, ok := p.required[file]; !ok {
+		p.required[file] = readRequiredFieldIDs(p.source(file))
+	}
+	return p.required[file]
+}
+
+var (
+	structDeclaration = regexp.MustCompile(`^\s*(?:struct|union|exception)\s+(\w+)`)
+	requiredField     = regexp.MustCompile(`(?:^|[{,;])\s*(\d+)\s*:\s*required\b`)
+)
+
+// readRequiredFieldIDs returns the ids of the required fields of the structs declared in src, by
+// struct name. The thrift parser does not tell required fields from those with default
+// requiredness, so they are read from the source.
+func readRequiredFieldIDs(src *source) map[string]map[int]bool {
+	required := map[string]map[int]bool{}
+	name, depth, opened := "", 0, false
+	for _, line := range src.code {
+		if match := structDeclaration.FindStringSubmatch(line); depth == 0 && match != nil {
+			name = match[1]
+		}
+		if name != "" {
+			for _, match := range requiredField.FindAllStringSubmatch(line, -1) {
+				id, _ := strconv.Atoi(match[1])
+				if required[name] == nil {
+					required[name] = map[int]bool{}
+				}
+				required[name][id] = true
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth > 0 {
+			opened = true
+		} else if opened {
+			name, depth, opened = "", 0, false
+		}
+	}
+	return required
+}
+
+// formatDefault returns a default value of the thrift parser as it would be written in thrift.
+func formatDefault(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strconv.Quote(value)
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = formatDefault(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []parser.KeyValue:
+		entries := make([]string, len(value))
+		for i, entry := range value {
+			entries[i] = formatDefault(entry.Key) + ": " + formatDefault(entry.Value)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,139 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+const structsSource = `namespace go example
+
+// 9: required in a comment
+struct User {
+  1: required string name
+  2: optional i32 age = 18,
+  3: map<string, i32> scores = {"a": 1}; 4: required list<string> tags
+}
+
+struct Request
+{
+  1: required User user (note = "{")
+  2: string note
+}
+
+exception NotFound { 1: required string id }
+
+struct Flags { /* first */ 1: required bool a, /* then
+  3: required bool c */ 2: bool b
+  /* last */ 4:required bool d
+}
+`
+
+func writeStructsSource(t *testing.T) string {
+	file, err := ioutil.TempFile("", "structs.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(structsSource); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func TestReadRequiredFieldIDs(t *testing.T) {
+	expected := map[string]map[int]bool{
+		"User":     {1: true, 4: true},
+		"Request":  {1: true},
+		"NotFound": {1: true},
+		"Flags":    {1: true, 4: true},
+	}
+	if required := readRequiredFieldIDs(newSource(structsSource)); !reflect.DeepEqual(required, expected) {
+		t.Errorf("readRequiredFieldIDs => %v, want %v", required, expected)
+	}
+}
+
+func TestParseArgStruct(t *testing.T) {
+	file := writeStructsSource(t)
+	defer os.Remove(file)
+	p := &Parser{
+		mainFile: file,
+		imports:  map[string]bool{},
+		thrift: map[string]*parser.Thrift{
+			file: {
+				Namespaces: map[string]string{"go": "example"},
+				Structs: map[string]*parser.Struct{
+					"User": {Name: "User", Fields: []*parser.Field{
+						{ID: 2, Name: "age", Optional: true, Type: &parser.Type{Name: "i32"}, Default: int64(18)},
+						{ID: 1, Name: "name", Type: &parser.Type{Name: "string"}},
+						{ID: 3, Name: "scores", Type: &parser.Type{Name: "map", KeyType: &parser.Type{Name: "string"},
+							ValueType: &parser.Type{Name: "i32"}}, Default: []parser.KeyValue{{Key: "a", Value: int64(1)}}},
+						{ID: 4, Name: "tags", Type: &parser.Type{Name: "list", ValueType: &parser.Type{Name: "string"}}},
+					}},
+					"Request": {Name: "Request", Fields: []*parser.Field{
+						{ID: 1, Name: "user", Type: &parser.Type{Name: "User"}},
+						{ID: 2, Name: "note", Type: &parser.Type{Name: "string"}},
+					}},
+				},
+			},
+		},
+	}
+
+	method := p.parseMethod(&parser.Service{Name: "Users"}, &parser.Method{
+		Name: "update",
+		Arguments: []*parser.Field{
+			{Name: "request", Type: &parser.Type{Name: "Request"}},
+			{Name: "users", Type: &parser.Type{Name: "list", ValueType: &parser.Type{Name: "User"}}},
+			{Name: "id", Type: &parser.Type{Name: "i64"}},
+		},
+	})
+
+	expectedRequired := []string{"request.user", "request.user.name", "request.user.tags", "users.name", "users.tags"}
+	if !reflect.DeepEqual(method.Required, expectedRequired) {
+		t.Errorf("Required => %v, want %v", method.Required, expectedRequired)
+	}
+	if method.Request[1].Struct != nil || method.Request[2].Struct != nil {
+		t.Error("expected only struct args to have a Struct")
+	}
+
+	request := method.Request[0].Struct
+	if request == nil || request.Type != "*example.Request" || request.Constructor != "example.NewRequest" {
+		t.Fatalf("unexpected Struct for the request arg: %+v", request)
+	}
+	user := p.parseArgStruct(&parser.Type{Name: "User"}, "*example.User")
+	expectedFields := []*Field{
+		{ID: 1, Name: "name", FieldName: "Name", Required: true},
+		{ID: 2, Name: "age", FieldName: "Age", Optional: true, Default: "18"},
+		{ID: 3, Name: "scores", FieldName: "Scores", Default: `{"a": 1}`},
+		{ID: 4, Name: "tags", FieldName: "Tags", Required: true},
+	}
+	if !reflect.DeepEqual(user.Fields, expectedFields) {
+		t.Errorf("Fields => %v, want %v", user.Fields, expectedFields)
+	}
+	if defaults := user.Defaults(); len(defaults) != 2 || defaults[0].Name != "age" {
+		t.Errorf("expected the age and scores defaults, received %v", defaults)
+	}
+}
+
+func TestFormatDefault(t *testing.T) {
+	defaults := []struct {
+		in       interface{}
+		expected string
+	}{
+		{nil, ""},
+		{int64(5), "5"},
+		{1.5, "1.5"},
+		{"a \"b\"", `"a \"b\""`},
+		{parser.Identifier("Status.ACTIVE"), "Status.ACTIVE"},
+		{[]interface{}{int64(1), "a"}, `[1, "a"]`},
+	}
+
+	for _, tc := range defaults {
+		if actual := formatDefault(tc.in); actual != tc.expected {
+			t.Errorf("formatDefault(%#v) => %q, want %q", tc.in, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package gen
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+// symbolKind is the kind of a thrift declaration.
+type symbolKind int
+
+const (
+	symbolStruct symbolKind = iota + 1
+	symbolUnion
+	symbolException
+	symbolEnum
+	symbolTypedef
+	symbolConst
+	symbolService
+)
+
+var symbolKindNames = map[symbolKind]string{
+	symbolStruct:    "struct",
+	symbolUnion:     "union",
+	symbolException: "exception",
+	symbolEnum:      "enum",
+	symbolTypedef:   "typedef",
+	symbolConst:     "const",
+	symbolService:   "service",
+}
+
+func (k symbolKind) String() string {
+	return symbolKindNames[k]
+}
+
+// symbols returns the kinds of the declarations of file by name, built once per file. Names
+// declared as several kinds are an error.
+func (p *Parser) symbols(file string) map[string]symbolKind {
+	if p.symbolTables == nil {
+		p.symbolTables = map[string]map[string]symbolKind{}
+	}
+	if table, ok := p.symbolTables[file]; ok {
+		return table
+	}
+
+	thrift := p.thrift[file]
+	table := map[string]symbolKind{}
+	declare := func(names []string, kind symbolKind) {
+		sort.Strings(names)
+		for _, name := range names {
+			if declared, ok := table[name]; ok {
+				p.fileErrorf(file, name, "%s declared twice, as %s and %s", name, declared, kind)
+				continue
+			}
+			table[name] = kind
+		}
+	}
+	declare(structNames(thrift.Structs), symbolStruct)
+	declare(structNames(thrift.Unions), symbolUnion)
+	declare(structNames(thrift.Exceptions), symbolException)
+	names := []string{}
+	for name := range thrift.Enums {
+		names = append(names, name)
+	}
+	declare(names, symbolEnum)
+	names = []string{}
+	for name := range thrift.Typedefs {
+		names = append(names, name)
+	}
+	declare(names, symbolTypedef)
+	names = []string{}
+	for name := range thrift.Constants {
+		names = append(names, name)
+	}
+	declare(names, symbolConst)
+	names = []string{}
+	for name := range thrift.Services {
+		names = append(names, name)
+	}
+	declare(names, symbolService)
+
+	p.symbolTables[file] = table
+	return table
+}
+
+// clearTables forgets the symbols and required fields read from previously parsed files.
+func (p *Parser) clearTables() {
+	p.symbolTables = nil
+	p.required = nil
+	p.sources = nil
+}
+
+// lookupType returns the file declaring the type typeName referenced from file, its name within
+// that file and its kind. Unknown includes and types, and names that are not types, are recorded
+// as errors and not ok.
+func (p *Parser) lookupType(file, typeName string) (typeFile, name string, kind symbolKind, ok bool) {
+	typeFile, name = p.resolveName(file, typeName)
+	if p.thrift[typeFile] == nil {
+		p.errorf(typeName, "unknown include %s in type %s", strings.SplitN(typeName, ".", 2)[0], typeName)
+		return "", "", 0, false
+	}
+	kind, ok = p.symbols(typeFile)[name]
+	switch {
+	case !ok:
+		p.errorf(typeName, "unknown type %s", typeName)
+		return "", "", 0, false
+	case kind == symbolConst || kind == symbolService:
+		p.errorf(typeName, "%s is a %s, not a type", typeName, kind)
+		return "", "", 0, false
+	}
+	return typeFile, name, kind, true
+}
+
+// structNames returns the names of structs.
+func structNames(structs map[string]*parser.Struct) []string {
+	names := make([]string, 0, len(structs))
+	for name := range structs {
+		names = append(names, name)
+	}
+	return names
+}
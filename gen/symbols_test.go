@@ -0,0 +1,91 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/alecthomas/go-thrift/parser"
+)
+
+func symbolsParser() *Parser {
+	return &Parser{
+		mainFile: "/main.thrift",
+		imports:  map[string]bool{},
+		thrift: map[string]*parser.Thrift{
+			"/main.thrift": {
+				Includes:   map[string]string{"shared": "/shared.thrift"},
+				Namespaces: map[string]string{"go": "example.main"},
+				Structs:    map[string]*parser.Struct{"Request": {Name: "Request"}},
+				Unions:     map[string]*parser.Struct{"Choice": {Name: "Choice"}},
+				Enums:      map[string]*parser.Enum{"status": {Name: "status"}},
+				Typedefs: map[string]*parser.Typedef{
+					"Ids": {Type: &parser.Type{Name: "list", ValueType: &parser.Type{Name: "i64"}}},
+				},
+				Constants: map[string]*parser.Constant{"MAX": {Name: "MAX", Type: &parser.Type{Name: "i32"}}},
+				Services:  map[string]*parser.Service{"Users": {Name: "Users"}},
+			},
+			"/shared.thrift": {
+				Namespaces: map[string]string{"go": "example.shared"},
+				Exceptions: map[string]*parser.Struct{"NotFound": {Name: "NotFound"}},
+				Typedefs:   map[string]*parser.Typedef{"UserId": {Type: &parser.Type{Name: "string"}}},
+			},
+		},
+	}
+}
+
+func TestParseType(t *testing.T) {
+	types := []struct {
+		in       *parser.Type
+		expected string
+	}{
+		{&parser.Type{Name: "i8"}, "int8"},
+		{&parser.Type{Name: "binary"}, "[]byte"},
+		{&parser.Type{Name: "Request"}, "*main.Request"},
+		{&parser.Type{Name: "Choice"}, "*main.Choice"},
+		{&parser.Type{Name: "shared.NotFound"}, "*shared.NotFound"},
+		{&parser.Type{Name: "status"}, "main.Status"},
+		{&parser.Type{Name: "Ids"}, "main.Ids"},
+		{&parser.Type{Name: "shared.UserId"}, "shared.UserId"},
+		{&parser.Type{Name: "set", ValueType: &parser.Type{Name: "status"}}, "map[main.Status]bool"},
+		{&parser.Type{Name: "map", KeyType: &parser.Type{Name: "shared.UserId"},
+			ValueType: &parser.Type{Name: "list", ValueType: &parser.Type{Name: "Request"}}}, "map[shared.UserId][]*main.Request"},
+	}
+
+	p := symbolsParser()
+	for _, tc := range types {
+		if actual := p.parseType(tc.in); actual != tc.expected {
+			t.Errorf("parseType(%s) => %q, want %q", tc.in.Name, actual, tc.expected)
+		}
+	}
+}
+
+func TestParseTypeErrors(t *testing.T) {
+	errors := []struct {
+		in       string
+		expected string
+	}{
+		{"Missing", "/main.thrift: unknown type Missing"},
+		{"shared.Missing", "/main.thrift: unknown type shared.Missing"},
+		{"other.Request", "/main.thrift: unknown include other in type other.Request"},
+		{"MAX", "/main.thrift: MAX is a const, not a type"},
+		{"Users", "/main.thrift: Users is a service, not a type"},
+	}
+
+	for _, tc := range errors {
+		p := symbolsParser()
+		if goType := p.parseType(&parser.Type{Name: tc.in}); goType != "interface{}" {
+			t.Errorf("parseType(%s) => %q, want interface{}", tc.in, goType)
+		}
+		if errs := p.takeErrors(); errs.Error() != tc.expected {
+			t.Errorf("parseType(%s) errors => %q, want %q", tc.in, errs, tc.expected)
+		}
+	}
+
+	p := symbolsParser()
+	p.thrift["/main.thrift"].Enums["Request"] = &parser.Enum{Name: "Request"}
+	if goType := p.parseType(&parser.Type{Name: "Request"}); goType != "*main.Request" {
+		t.Errorf("expected the first declaration to be used, received %q", goType)
+	}
+	if errs := p.takeErrors(); errs.Error() != "/main.thrift: Request declared twice, as struct and enum" {
+		t.Errorf("expected conflicting declarations to be an error, received %q", errs)
+	}
+}
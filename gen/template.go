@@ -0,0 +1,250 @@
+package gen
+
+import (
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Template is the built-in template gen-client renders a Thrift with. It is made of named blocks,
+// each of which may be overridden by {{define}}ing a template of the same name:
+//
+//	file          the whole file, rendered with the Thrift
+//	header        the package doc and clause, rendered with the Thrift
+//	imports       the import declaration, rendered with the Thrift
+//	service       all the code for a service, rendered with a ServiceData
+//	client        the client type declaration, rendered with a ServiceData
+//	methodVars    the rpc.Method variables, rendered with a ServiceData
+//	constructor   the client constructor, rendered with a ServiceData
+//	thriftClient  the newThriftClient method, rendered with a ServiceData
+//	method        all the code for a method, rendered with a MethodData
+//	options       the ClientOptions for a method, rendered with a MethodData
+//	builders      the constructors of a method's struct args, rendered with a MethodData
+//	call          the method wrapping the thrift client's, rendered with a MethodData
+//	contextCall   the Context variant of call, rendered with a MethodData
+//	invalidate    the cache invalidation method, rendered with a MethodData
+//
+// The functions of TemplateFuncs are available to it.
+const Template = `
+{{- block "file" . -}}
+{{block "header" .}}
+// Package {{.Package}} wraps {{.ThriftImport}} with RPC-specific logic.
+// @generated
+package {{.Package}}
+{{end}}
+{{block "imports" .}}
+import (
+	"context"
+{{- if .Caches}}
+	"time"
+{{- end}}
+
+	"{{or .LibraryImport "git.apache.org/thrift.git/lib/go/thrift"}}"
+{{range $import := .Imports}}
+	"{{$import}}"
+{{- end}}
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+{{end}}
+{{- range $service := .Services}}
+{{block "service" (withService $ $service)}}
+{{- block "client" .}}
+// {{.Service.Name}}RPCClient implements {{.Service.Name}} with RPC-specific logic.
+{{- with .Service.Doc}}
+//
+{{comment .}}
+{{- end}}
+type {{.Service.Name}}RPCClient rpc.Client
+{{end}}
+{{- block "methodVars" .}}
+{{- if .Service.Methods}}
+// Methods of {{.Service.Name}}, as seen by rpc.Client interceptors.
+var (
+{{- range $method := .Service.Methods}}
+	{{$.Service.Name}}{{$method.Name}}Method = &rpc.Method{Service: {{quote $.Service.ThriftName}}, Name: {{quote $method.ThriftName}}
+		{{- if $method.Idempotent}}, Idempotent: true{{end}}
+		{{- if $method.ReadOnly}}, ReadOnly: true{{end}}
+		{{- if $method.CacheTTL}}, CacheTTL: {{$method.CacheTTLExpr}}{{end}}
+		{{- if $method.Sensitive}}, Sensitive: {{printf "%#v" $method.Sensitive}}{{end}}
+		{{- if $method.Required}}, Required: {{printf "%#v" $method.Required}}{{end}}}
+{{- end}}
+)
+{{end}}
+{{- end}}
+{{- block "constructor" .}}
+// New{{.Service.Name}}RPCClient returns a new {{.Service.Name}}RPCClient.
+{{- if .Multiplexed}}
+// Calls are multiplexed as {{.Service.ThriftName}}, unless options include another rpc.MultiplexedOption.
+{{- end}}
+func New{{.Service.Name}}RPCClient(transportFactory rpc.TransportFactory, options ...rpc.ClientOption) *{{.Service.Name}}RPCClient {
+{{- if .Multiplexed}}
+	options = append([]rpc.ClientOption{rpc.MultiplexedOption({{quote .Service.ThriftName}})}, options...)
+{{- end}}
+	client := rpc.NewClient(transportFactory, options...)
+	return (*{{.Service.Name}}RPCClient)(client)
+}
+{{end}}
+{{- block "thriftClient" .}}
+// newThriftClient returns a {{.ThriftPackage}}.{{.Service.Name}} sending calls over transport.
+func (c *{{.Service.Name}}RPCClient) newThriftClient(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) {{.ThriftPackage}}.{{.Service.Name}} {
+	return {{.ThriftPackage}}.New{{.Service.Name}}ClientFactory(transport, protocolFactory)
+}
+{{end}}
+{{- range $method := .Service.Methods}}
+{{- block "method" (withMethod $ $method)}}
+{{- block "options" .}}
+{{- if .Method.ReadOnly}}
+// {{.Service.Name}}{{.Method.Name}}CoalescingOption coalesces identical concurrent calls to {{.Method.Name}}.
+func {{.Service.Name}}{{.Method.Name}}CoalescingOption() rpc.ClientOption {
+	return rpc.CoalescingOption({{.Service.Name}}{{.Method.Name}}Method)
+}
+{{end}}
+{{- end}}
+{{- block "builders" .}}
+{{- range $arg := .Method.Request}}
+{{- with $arg.Struct}}
+// New{{$.Service.Name}}{{$.Method.Name}}{{$arg.FieldName}} returns a new {{.ThriftName}} for the {{$arg.Name}} arg of {{$.Method.Name}}, with
+// the thrift defaults of its fields applied.
+{{- with .Defaults}}
+//
+{{- range .}}
+//   - {{.Name}}: {{.Default}}
+{{- end}}
+{{- end}}
+{{- if $.Method.Requires $arg}}
+//
+// Calls to {{$.Method.Name}} fail with an rpc.ValidationError, without being sent, unless its
+// required fields are set.
+{{- end}}
+func New{{$.Service.Name}}{{$.Method.Name}}{{$arg.FieldName}}() {{.Type}} {
+	return {{.Constructor}}()
+}
+{{end}}
+{{- end}}
+{{- end}}
+{{- block "call" .}}
+{{if .Method.Doc}}{{godoc .Method.Name .Method.Doc}}{{else}}// {{.Method.Name}} wraps the underlying method.{{end}}
+{{- with .Method.ArgDocs}}
+//
+{{comment .}}
+{{- end}}
+func (c *{{.Service.Name}}RPCClient) {{.Method.Name}}({{.Method.ArgDeclarations}}) (
+	{{- if .Method.ResponseType}}resp {{.Method.ResponseType}}, {{end}}err error) {
+	return c.{{.Method.Name}}Context(context.Background(){{if .Method.Request}}, {{.Method.Args}}{{end}})
+}
+{{end}}
+{{- block "contextCall" .}}
+// {{.Method.Name}}Context wraps the underlying method, passing ctx to the client's interceptors.
+func (c *{{.Service.Name}}RPCClient) {{.Method.Name}}Context(ctx context.Context{{if .Method.Request}}, {{.Method.ArgDeclarations}}{{end}}) (
+	{{- if .Method.ResponseType}}resp {{.Method.ResponseType}}, {{end}}err error) {
+	{{if .Method.ResponseType}}result, err := {{else}}_, err = {{end -}}
+	(*rpc.Client)(c).Invoke(ctx, {{.Service.Name}}{{.Method.Name}}Method, &{{.ThriftPackage}}.{{.Method.ArgsStruct}}{ {{- .Method.ArgFields -}} },
+		func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+			return {{if not .Method.ResponseType}}nil, {{end}}c.newThriftClient(transport, protocolFactory).{{.Method.Name}}({{.Method.Args}})
+		})
+{{- if .Method.ResponseType}}
+	if err == nil {
+		// interceptors may return no result without an error
+		resp, _ = result.({{.Method.ResponseType}})
+	}
+{{- end}}
+	return
+}
+{{end}}
+{{- block "invalidate" .}}
+{{- if .Method.CacheTTL}}
+// Invalidate{{.Method.Name}} removes the cached result of calling {{.Method.Name}} with the given args.
+func (c *{{.Service.Name}}RPCClient) Invalidate{{.Method.Name}}({{.Method.ArgDeclarations}}) error {
+	return (*rpc.Client)(c).Invalidate({{.Service.Name}}{{.Method.Name}}Method, &{{.ThriftPackage}}.{{.Method.ArgsStruct}}{ {{- .Method.ArgFields -}} })
+}
+{{end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+// ServiceData is the data service templates are rendered with.
+type ServiceData struct {
+	*Thrift
+	Service *Service
+}
+
+// MethodData is the data method templates are rendered with.
+type MethodData struct {
+	ServiceData
+	Method *Method
+}
+
+// TemplateFuncs returns the functions available to templates, in addition to the text/template
+// builtins:
+//
+//	withService THRIFT SERVICE   returns the ServiceData of a service
+//	withMethod SERVICEDATA METHOD returns the MethodData of a method
+//	titleCase NAME               converts a thrift name like the thrift go generator
+//	quote STRING                 quotes a string as a go literal
+//	join LIST SEPARATOR          joins a list of strings
+//	lower STRING, upper STRING   change the case of a string
+//	comment TEXT                 turns text into // comment lines
+//	godoc NAME DOC               turns doc into the comment lines of a declaration named name
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"withService": func(thrift *Thrift, service *Service) ServiceData {
+			return ServiceData{Thrift: thrift, Service: service}
+		},
+		"withMethod": func(service ServiceData, method *Method) MethodData {
+			return MethodData{ServiceData: service, Method: method}
+		},
+		"titleCase": publicize,
+		"quote":     strconv.Quote,
+		"join":      strings.Join,
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"comment":   comment,
+		"godoc":     godoc,
+	}
+}
+
+// comment turns text into // comment lines.
+func comment(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("// "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// godoc turns doc into the comment lines of a go declaration named name, starting the first
+// sentence with name as godoc expects, e.g. "Gets a user." documents Get as "Get gets a user.".
+func godoc(name, doc string) string {
+	first, rest := doc, ""
+	if i := strings.IndexAny(doc, " \n"); i >= 0 {
+		first, rest = doc[:i], doc[i:]
+	}
+	switch {
+	case strings.EqualFold(strings.TrimRight(first, ".,:"), name):
+		first = name + first[len(name):]
+	case len(first) > 1 && unicode.IsUpper(rune(first[0])) && !unicode.IsUpper(rune(first[1])):
+		// a capitalized word, rather than an initialism
+		first = name + " " + strings.ToLower(first[:1]) + first[1:]
+	default:
+		first = name + " " + first
+	}
+	return comment(first + rest)
+}
+
+// NewTemplate returns Template, with the templates defined in files overriding its blocks.
+// Executing it renders the "file" block.
+func NewTemplate(files ...string) (*template.Template, error) {
+	t, err := template.New("gen-client").Funcs(TemplateFuncs()).Parse(Template)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return t, nil
+	}
+	return t.ParseFiles(files...)
+}
@@ -0,0 +1,112 @@
+package gen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var templateThrift = &Thrift{
+	File:          "/example.thrift",
+	Package:       "example",
+	ThriftPackage: "services",
+	ThriftImport:  "example/services",
+	Imports:       []string{"example/services"},
+	Services: []*Service{{Name: "Example", ThriftName: "example", Doc: "Stores examples.", Methods: []*Method{{
+		Name: "Get", ThriftName: "get", ArgsStruct: "ExampleGetArgs", ResponseType: "string", Doc: "Gets an example.",
+		Request: []*Arg{{Name: "id", Type: "int64", FieldName: "ID", Doc: "the id of the example"}},
+	}, {
+		Name: "Put", ThriftName: "put", ArgsStruct: "ExamplePutArgs", Required: []string{"example.name"},
+		Request: []*Arg{{Name: "example", Type: "*services.Example", FieldName: "Example", Struct: &Struct{
+			ThriftName: "Example", Type: "*services.Example", Constructor: "services.NewExample", Fields: []*Field{
+				{ID: 1, Name: "name", FieldName: "Name", Required: true},
+				{ID: 2, Name: "limit", FieldName: "Limit", Default: "10"},
+			},
+		}}, {Name: "options", Type: "*services.Options", FieldName: "Options", Struct: &Struct{
+			ThriftName: "Options", Type: "*services.Options", Constructor: "services.NewOptions", Fields: []*Field{
+				{ID: 1, Name: "overwrite", FieldName: "Overwrite", Optional: true},
+			},
+		}}},
+	}}}},
+}
+
+func render(t *testing.T, files ...string) string {
+	tmpl, err := NewTemplate(files...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, templateThrift); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	formatted, err := Format(templateThrift, out.Bytes())
+	if err != nil {
+		t.Fatalf("expected the output to parse: %v", err)
+	}
+	return string(formatted)
+}
+
+func TestNewTemplate(t *testing.T) {
+	builtIn := render(t)
+	for _, expected := range []string{
+		"package example\n",
+		"// ExampleRPCClient implements Example with RPC-specific logic.\n//\n// Stores examples.\ntype",
+		"// Get gets an example.\n//\n//   - id: the id of the example\nfunc",
+		"func (c *ExampleRPCClient) Get(id int64) (resp string, err error) {\n",
+		"&services.ExampleGetArgs{ID: id}",
+		"resp, _ = result.(string)",
+		`Name: "put", Required: []string{"example.name"}}`,
+		"// the thrift defaults of its fields applied.\n//\n//   - limit: 10\n",
+		"func NewExamplePutExample() *services.Example {\n\treturn services.NewExample()\n}\n",
+		"// Calls to Put fail with an rpc.ValidationError, without being sent, unless its\n// required fields are set.\nfunc NewExamplePutExample",
+		"// NewExamplePutOptions returns a new Options for the options arg of Put, with\n// the thrift defaults of its fields applied.\nfunc NewExamplePutOptions",
+	} {
+		if !strings.Contains(builtIn, expected) {
+			t.Errorf("expected the built-in template to render %q, received:\n%s", expected, builtIn)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	override := filepath.Join(dir, "override.tmpl")
+	content := `{{define "call"}}
+{{comment (printf "%s is %s.\nGenerated." .Method.Name (lower .Service.Name))}}
+func (c *{{.Service.Name}}RPCClient) {{.Method.Name}}() {}
+{{end}}`
+	if err := ioutil.WriteFile(override, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	overridden := render(t, override)
+	if !strings.Contains(overridden, "// Get is example.\n// Generated.\nfunc (c *ExampleRPCClient) Get() {}\n") {
+		t.Errorf("expected the call block to be overridden, received:\n%s", overridden)
+	}
+	if !strings.Contains(overridden, "func (c *ExampleRPCClient) GetContext(") {
+		t.Errorf("expected the other blocks to be kept, received:\n%s", overridden)
+	}
+}
+
+func TestGodoc(t *testing.T) {
+	godocs := []struct {
+		name, doc string
+		expected  string
+	}{
+		{"Get", "Gets a user.", "// Get gets a user."},
+		{"Get", "get returns a user.", "// Get returns a user."},
+		{"Get", "returns a user.\n\nOr fails.", "// Get returns a user.\n//\n// Or fails."},
+		{"Get", "HTTP gets a user.", "// Get HTTP gets a user."},
+	}
+
+	for _, tc := range godocs {
+		actual := godoc(tc.name, tc.doc)
+		if actual != tc.expected {
+			t.Errorf("godoc(%q, %q) => %q, want %q", tc.name, tc.doc, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GeneratedError is an error in the go code generated for a thrift file, pointing at the service
+// and method the code was generated for, when known.
+type GeneratedError struct {
+	File    string // The thrift file.
+	Service string // The thrift name of the service, if any.
+	Method  string // The thrift name of the method, if any.
+	Line    int    // The line of the generated code.
+	Err     error
+}
+
+func (e *GeneratedError) Error() string {
+	location := e.File
+	if e.Service != "" {
+		location += ": service " + e.Service
+	}
+	if e.Method != "" {
+		location += ", method " + e.Method
+	}
+	return fmt.Sprintf("%s: generated line %d: %v", location, e.Line, e.Err)
+}
+
+// Format gofmts src, the code generated for thrift, returning a GeneratedError if it does not
+// parse.
+func Format(thrift *Thrift, src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		if errs, ok := err.(scanner.ErrorList); ok && len(errs) > 0 {
+			return nil, thrift.generatedError(src, errs[0].Pos.Line, errors.New(errs[0].Msg))
+		}
+		return nil, err
+	}
+	return formatted, nil
+}
+
+// TypeCheck type-checks src, the code generated for thrift, as fileName within the package of its
+// directory, returning a GeneratedError for the first error in it. The package is loaded with
+// go/packages, so the go command must be able to build it.
+func TypeCheck(thrift *Thrift, fileName string, src []byte) error {
+	fileName, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+	config := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax,
+		Dir:     filepath.Dir(fileName),
+		Overlay: map[string][]byte{fileName: src},
+	}
+	pkgs, err := packages.Load(config, ".")
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		for _, pkgErr := range pkg.Errors {
+			position := strings.SplitN(pkgErr.Pos, ":", 3)
+			if len(position) < 2 || position[0] != fileName {
+				continue
+			}
+			line := 0
+			fmt.Sscan(position[1], &line)
+			return thrift.generatedError(src, line, errors.New(pkgErr.Msg))
+		}
+	}
+	return nil
+}
+
+// generatedError returns a GeneratedError for err, at line of src, finding the service and method
+// it belongs to from the declarations preceding it.
+func (t *Thrift) generatedError(src []byte, line int, err error) *GeneratedError {
+	generatedErr := &GeneratedError{File: t.File, Line: line, Err: err}
+	lines := bytes.Split(src, []byte("\n"))
+	if line > len(lines) {
+		line = len(lines)
+	}
+	for i := line - 1; i >= 0; i-- {
+		if service, method := t.declaredBy(string(lines[i])); service != nil {
+			generatedErr.Service = service.ThriftName
+			if method != nil {
+				generatedErr.Method = method.ThriftName
+			}
+			break
+		}
+	}
+	return generatedErr
+}
+
+// declaredBy returns the service and method whose generated code line declares, if any.
+func (t *Thrift) declaredBy(line string) (*Service, *Method) {
+	for _, service := range t.Services {
+		for _, method := range service.Methods {
+			prefixes := []string{
+				"\t" + service.Name + method.Name + "Method ",
+				"func " + service.Name + method.Name + "CoalescingOption(",
+				"func (c *" + service.Name + "RPCClient) " + method.Name + "(",
+				"func (c *" + service.Name + "RPCClient) " + method.Name + "Context(",
+				"func (c *" + service.Name + "RPCClient) Invalidate" + method.Name + "(",
+			}
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(line, prefix) {
+					return service, method
+				}
+			}
+		}
+		if strings.HasPrefix(line, "type "+service.Name+"RPCClient ") ||
+			strings.HasPrefix(line, "func New"+service.Name+"RPCClient(") ||
+			strings.HasPrefix(line, "func (c *"+service.Name+"RPCClient) newThriftClient(") {
+			return service, nil
+		}
+	}
+	return nil, nil
+}
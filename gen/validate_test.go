@@ -0,0 +1,67 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var validateThrift = &Thrift{
+	File: "/example.thrift",
+	Services: []*Service{{Name: "Example", ThriftName: "example", Methods: []*Method{
+		{Name: "Get", ThriftName: "get"},
+		{Name: "Put", ThriftName: "put"},
+	}}},
+}
+
+func TestFormat(t *testing.T) {
+	formatted, err := Format(validateThrift, []byte("package p\n\n\n\nvar  x = 1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(formatted) != "package p\n\nvar x = 1\n" {
+		t.Errorf("expected formatted code, received %q", formatted)
+	}
+
+	src := "package p\n\ntype ExampleRPCClient struct{}\n\nfunc (c *ExampleRPCClient) Get() {}\n\nfunc (c *ExampleRPCClient) Put() map[ {}\n"
+	_, err = Format(validateThrift, []byte(src))
+	generatedErr, ok := err.(*GeneratedError)
+	if !ok {
+		t.Fatalf("expected a GeneratedError, received %v", err)
+	}
+	if generatedErr.Service != "example" || generatedErr.Method != "put" || generatedErr.Line != 7 {
+		t.Errorf("expected an error at line 7 in example.put, received %v", generatedErr)
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typecheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/typecheck\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "types.go"), []byte("package typecheck\n\ntype Int int32\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(dir, "example.go")
+
+	src := "package typecheck\n\ntype ExampleRPCClient struct{}\n\nfunc (c *ExampleRPCClient) Get() Int { return 0 }\n"
+	if err := TypeCheck(validateThrift, fileName, []byte(src)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	src += "\nfunc (c *ExampleRPCClient) PutContext() Missing { return 0 }\n"
+	err = TypeCheck(validateThrift, fileName, []byte(src))
+	generatedErr, ok := err.(*GeneratedError)
+	if !ok {
+		t.Fatalf("expected a GeneratedError, received %v", err)
+	}
+	if generatedErr.Method != "put" || !strings.Contains(generatedErr.Err.Error(), "Missing") {
+		t.Errorf("expected an error about Missing in example.put, received %v", generatedErr)
+	}
+}
@@ -1,8 +1,17 @@
 // Package retry provides types and functions used to retry functions.
 package retry
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
 const defaultMaxAttempts uint64 = 1
 
+// ErrNoAttempts is returned when the Retrier allows no attempts.
+var ErrNoAttempts = errors.New("retry: no attempts allowed")
+
 // AnyErr returns true if err != nil.
 func AnyErr(err error) bool {
 	return err != nil
@@ -55,21 +64,114 @@ func NewRetrier(options ...Option) *Retrier {
 }
 
 // Do calls fn a specified amount of times. It will either succeed and return nil,
-// or return the last error returned by fn.
+// or return the last error returned by fn, or ErrNoAttempts if no attempt is allowed.
 func (r *Retrier) Do(fn func() error) error {
+	return r.DoNotify(func(uint64) error { return fn() }, nil)
+}
+
+// DoNotify is like Do, but passes fn the zero-based attempt number and, if notify is not nil,
+// calls it with the time spent in each backoff, before the next attempt is made.
+func (r *Retrier) DoNotify(fn func(attempt uint64) error, notify func(attempt uint64, wait time.Duration)) error {
+	if r.maxAttempts == 0 {
+		return ErrNoAttempts
+	}
 	var err error
 	var i uint64
 	backoff := r.backoffFactory.New()
 
 	for ; i < r.maxAttempts; i++ {
-		err = fn()
+		err = fn(i)
 		if !r.isRetriable(err) {
 			return err
 		}
 		// ensure we will still make another attempt before backing off
 		if i+1 < r.maxAttempts {
+			start := time.Now()
 			backoff.Backoff(i)
+			if notify != nil {
+				notify(i, time.Since(start))
+			}
 		}
 	}
 	return err
 }
+
+// DoHedged is like Do, but hedges slow attempts: whenever the latest attempt has run for delay
+// without completing, another one is started concurrently. Hedged attempts count towards the
+// maximum number of attempts. When an attempt fails with a retriable error and no other attempt is
+// running, the next one is started after backing off, as with Do.
+//
+// DoHedged returns as soon as an attempt succeeds or fails with an error that is not retriable, or
+// ctx is done, and cancels the context passed to the attempts that are still running. fn is passed
+// the zero-based attempt number and the time spent backing off before the attempt. DoHedged returns
+// the number of the attempt whose error it returns, or ErrNoAttempts if no attempt is allowed.
+func (r *Retrier) DoHedged(ctx context.Context, delay time.Duration,
+	fn func(ctx context.Context, attempt uint64, wait time.Duration) error) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		attempt uint64
+		err     error
+	}
+	// buffered so that abandoned attempts can always report and exit
+	outcomes := make(chan outcome, r.maxAttempts)
+	var started, running uint64
+	start := func(wait time.Duration) {
+		attempt := started
+		started++
+		running++
+		go func() {
+			outcomes <- outcome{attempt: attempt, err: fn(ctx, attempt, wait)}
+		}()
+	}
+
+	if r.maxAttempts == 0 {
+		return 0, ErrNoAttempts
+	}
+	backoff := r.backoffFactory.New()
+	hedge := time.NewTimer(delay)
+	defer hedge.Stop()
+
+	start(0)
+	var last outcome
+	for running > 0 {
+		select {
+		case last = <-outcomes:
+			running--
+			if !r.isRetriable(last.err) {
+				return last.attempt, last.err
+			}
+			if running == 0 && started < r.maxAttempts {
+				begin := time.Now()
+				backoff.Backoff(last.attempt)
+				start(time.Since(begin))
+				resetTimer(hedge, delay)
+			}
+		case <-hedge.C:
+			if started < r.maxAttempts {
+				start(0)
+				hedge.Reset(delay)
+			}
+		case <-ctx.Done():
+			return last.attempt, ctx.Err()
+		}
+	}
+	return last.attempt, last.err
+}
+
+// MaxAttempts returns the maximum number of attempts the Retrier makes.
+func (r *Retrier) MaxAttempts() uint64 {
+	return r.maxAttempts
+}
+
+// resetTimer stops timer, discarding a pending expiry, and restarts it with d.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
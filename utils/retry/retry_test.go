@@ -1,8 +1,10 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 func makeFn(i int64) func() error {
@@ -70,3 +72,95 @@ func TestNewRetrier(t *testing.T) {
 		t.Errorf("retrier0.maxAttempts != defaultMaxAttempts, got %d instead", retrier0.maxAttempts)
 	}
 }
+
+func TestRetrier_DoNotify(t *testing.T) {
+	var attempts, notified []uint64
+	fn := makeFn(2)
+	retrier := NewRetrier(MaxAttemptsOption(3), BackoffOption(NoopBackoff))
+	err := retrier.DoNotify(
+		func(attempt uint64) error {
+			attempts = append(attempts, attempt)
+			return fn()
+		},
+		func(attempt uint64, wait time.Duration) {
+			notified = append(notified, attempt)
+		},
+	)
+	if err != nil {
+		t.Errorf("expected retrier to return nil, received %v", err)
+	}
+
+	expectedAttempts, expectedNotified := []uint64{0, 1, 2}, []uint64{0, 1}
+	if len(attempts) != len(expectedAttempts) || len(notified) != len(expectedNotified) {
+		t.Fatalf("expected attempts %v and notifications %v, received %v and %v",
+			expectedAttempts, expectedNotified, attempts, notified)
+	}
+	for i := range expectedAttempts {
+		if attempts[i] != expectedAttempts[i] {
+			t.Errorf("expected attempts %v, received %v", expectedAttempts, attempts)
+		}
+	}
+}
+
+func TestRetrier_DoHedged(t *testing.T) {
+	// the first attempt hangs until canceled, the hedge succeeds
+	retrier := NewRetrier(MaxAttemptsOption(3), BackoffOption(NoopBackoff))
+	canceled := make(chan bool, 1)
+	attempt, err := retrier.DoHedged(context.Background(), 10*time.Millisecond,
+		func(ctx context.Context, attempt uint64, wait time.Duration) error {
+			if attempt == 0 {
+				<-ctx.Done()
+				canceled <- true
+				return ctx.Err()
+			}
+			return nil
+		})
+	if attempt != 1 || err != nil {
+		t.Errorf("expected attempt 1 to succeed, received attempt %d with %v", attempt, err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected the slow attempt to be canceled")
+	}
+
+	// failures are retried after backing off, within the attempt budget
+	var waits []uint64
+	backoff := FunctionalBackoff(func(i uint64) { waits = append(waits, i) })
+	retrier = NewRetrier(MaxAttemptsOption(2), BackoffOption(backoff))
+	attempt, err = retrier.DoHedged(context.Background(), time.Minute,
+		func(ctx context.Context, attempt uint64, wait time.Duration) error {
+			return errors.New("err")
+		})
+	if attempt != 1 || err == nil {
+		t.Errorf("expected attempt 1 to fail, received attempt %d with %v", attempt, err)
+	}
+	if len(waits) != 1 {
+		t.Errorf("expected 1 backoff, received %d", len(waits))
+	}
+}
+
+func TestRetrier_DoNoAttempts(t *testing.T) {
+	retrier := NewRetrier(MaxAttemptsOption(0))
+	calls := 0
+	err := retrier.Do(func() error {
+		calls++
+		return nil
+	})
+	if err != ErrNoAttempts || calls != 0 {
+		t.Errorf("expected ErrNoAttempts without calls, received %v after %d calls", err, calls)
+	}
+}
+
+func TestRetrier_DoHedgedNoAttempts(t *testing.T) {
+	retrier := NewRetrier(MaxAttemptsOption(0))
+	calls := 0
+	_, err := retrier.DoHedged(context.Background(), time.Minute,
+		func(ctx context.Context, attempt uint64, wait time.Duration) error {
+			calls++
+			return nil
+		})
+	if err != ErrNoAttempts || calls != 0 {
+		t.Errorf("expected ErrNoAttempts without calls, received %v after %d calls", err, calls)
+	}
+}
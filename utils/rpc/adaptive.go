@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter limits calls in flight to a limit it adjusts from their outcomes, in the style
+// of TCP congestion control (AIMD): the limit grows by one for every call that succeeds while the
+// limiter is at least half used, and is multiplied by a backoff ratio for every call that fails with
+// a transport error, runs past its deadline or takes longer than a latency threshold. Other errors, such as exceptions
+// declared in the IDL, count as successes, and canceled calls are ignored.
+//
+// An AdaptiveLimiter may be shared between clients and methods, see AdaptiveLimitOption.
+type AdaptiveLimiter struct {
+	minLimit         float64
+	maxLimit         float64
+	backoffRatio     float64
+	latencyThreshold time.Duration
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	released chan struct{} // closed and replaced whenever a call is released
+}
+
+// AdaptiveLimiterOption configures an AdaptiveLimiter.
+type AdaptiveLimiterOption func(*AdaptiveLimiter)
+
+// InitialLimitOption sets the limit the AdaptiveLimiter starts at.
+// Defaults to 20.
+func InitialLimitOption(limit int) AdaptiveLimiterOption {
+	return func(l *AdaptiveLimiter) {
+		l.limit = float64(limit)
+	}
+}
+
+// LimitBoundsOption sets the bounds of the AdaptiveLimiter's limit.
+// Defaults to [1, 200].
+func LimitBoundsOption(min, max int) AdaptiveLimiterOption {
+	return func(l *AdaptiveLimiter) {
+		l.minLimit = float64(min)
+		l.maxLimit = float64(max)
+	}
+}
+
+// BackoffRatioOption sets the ratio the limit is multiplied by when a call fails.
+// Defaults to 0.9.
+func BackoffRatioOption(ratio float64) AdaptiveLimiterOption {
+	return func(l *AdaptiveLimiter) {
+		l.backoffRatio = ratio
+	}
+}
+
+// LatencyThresholdOption sets the latency past which calls count as failed.
+// Defaults to 5s.
+func LatencyThresholdOption(threshold time.Duration) AdaptiveLimiterOption {
+	return func(l *AdaptiveLimiter) {
+		l.latencyThreshold = threshold
+	}
+}
+
+// NewAdaptiveLimiter returns a new AdaptiveLimiter.
+func NewAdaptiveLimiter(options ...AdaptiveLimiterOption) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		minLimit:         1,
+		maxLimit:         200,
+		backoffRatio:     0.9,
+		latencyThreshold: 5 * time.Second,
+		limit:            20,
+		released:         make(chan struct{}),
+	}
+	for _, option := range options {
+		option(l)
+	}
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, l.limit))
+	return l
+}
+
+// Limit returns the number of calls currently allowed in flight.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of calls currently in flight.
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// AdaptiveLimitOption limits calls to the client with limiter.
+func AdaptiveLimitOption(limiter *AdaptiveLimiter, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.limiters = append(client.limiters, &adaptiveAdmission{limiter, newLimitConfig(options).failFast})
+	}
+}
+
+// MethodAdaptiveLimitOption limits calls to method with limiter, in addition to any limit on the
+// client.
+func MethodAdaptiveLimitOption(method *Method, limiter *AdaptiveLimiter, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.addMethodLimiter(method, &adaptiveAdmission{limiter, newLimitConfig(options).failFast})
+	}
+}
+
+// adaptiveAdmission admits calls through an AdaptiveLimiter.
+type adaptiveAdmission struct {
+	*AdaptiveLimiter
+	failFast bool
+}
+
+func (a *adaptiveAdmission) acquire(ctx context.Context) (func(error), string, error) {
+	for {
+		a.mu.Lock()
+		if float64(a.inFlight) < math.Floor(a.limit) {
+			a.inFlight++
+			a.mu.Unlock()
+			start := time.Now()
+			return func(err error) { a.release(time.Since(start), err) }, "", nil
+		}
+		released := a.released
+		a.mu.Unlock()
+
+		if a.failFast {
+			return nil, RejectedMaxInFlight, nil
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, RejectedMaxInFlight, ctx.Err()
+		}
+	}
+}
+
+// release ends a call that took rtt and returned err, adjusting the limit.
+func (l *AdaptiveLimiter) release(rtt time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inFlight := l.inFlight
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+
+	switch {
+	case errors.Is(err, context.Canceled) || ErrorClass(err) == ErrorClassRejected:
+	case ErrorClass(err) == ErrorClassTransport || errors.Is(err, context.DeadlineExceeded) || rtt > l.latencyThreshold:
+		l.limit = math.Max(l.minLimit, l.limit*l.backoffRatio)
+	case float64(inFlight*2) >= l.limit:
+		l.limit = math.Min(l.maxLimit, l.limit+1)
+	}
+}
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := NewAdaptiveLimiter(InitialLimitOption(2), LimitBoundsOption(1, 3), LatencyThresholdOption(time.Second))
+	admission := &adaptiveAdmission{AdaptiveLimiter: limiter, failFast: true}
+
+	acquire := func() func(error) {
+		release, reason, _ := admission.acquire(context.Background())
+		if reason != "" {
+			t.Fatalf("expected the call to be admitted, received %q", reason)
+		}
+		return release
+	}
+
+	// successes at full use grow the limit, up to the max
+	for i := 0; i < 3; i++ {
+		release1, release2 := acquire(), acquire()
+		release1(nil)
+		release2(nil)
+	}
+	if limiter.Limit() != 3 {
+		t.Errorf("expected limit 3, received %d", limiter.Limit())
+	}
+
+	releases := []func(error){acquire(), acquire(), acquire()}
+	if _, reason, _ := admission.acquire(context.Background()); reason != RejectedMaxInFlight {
+		t.Errorf("expected calls over the limit to be rejected, received %q", reason)
+	}
+	if limiter.InFlight() != 3 {
+		t.Errorf("expected 3 calls in flight, received %d", limiter.InFlight())
+	}
+
+	// failures shrink the limit, down to the min
+	for _, release := range releases {
+		release(thrift.NewTTransportException(thrift.TIMED_OUT, "timeout"))
+	}
+	if limiter.Limit() != 2 {
+		t.Errorf("expected limit 2 after 3 failures, received %d", limiter.Limit())
+	}
+	for i := 0; i < 20; i++ {
+		acquire()(context.DeadlineExceeded)
+	}
+	if limiter.Limit() != 1 {
+		t.Errorf("expected limit 1, received %d", limiter.Limit())
+	}
+
+	// slow calls count as failures, canceled calls are ignored
+	limiter = NewAdaptiveLimiter(InitialLimitOption(10))
+	limiter.inFlight++
+	limiter.release(10*time.Second, nil)
+	if limiter.Limit() != 9 {
+		t.Errorf("expected slow calls to shrink the limit to 9, received %d", limiter.Limit())
+	}
+	limiter.inFlight++
+	limiter.release(time.Millisecond, context.Canceled)
+	if limiter.Limit() != 9 {
+		t.Errorf("expected canceled calls to keep the limit at 9, received %d", limiter.Limit())
+	}
+
+	// wrapped context errors count like the errors they wrap
+	limiter.inFlight++
+	limiter.release(time.Millisecond, fmt.Errorf("get: %w", context.Canceled))
+	if limiter.Limit() != 9 {
+		t.Errorf("expected wrapped canceled calls to keep the limit at 9, received %d", limiter.Limit())
+	}
+	limiter.inFlight++
+	limiter.release(time.Millisecond, fmt.Errorf("get: %w", context.DeadlineExceeded))
+	if limiter.Limit() != 8 {
+		t.Errorf("expected wrapped deadlines to shrink the limit to 8, received %d", limiter.Limit())
+	}
+}
+
+func TestClient_AdaptiveLimit(t *testing.T) {
+	limiter := NewAdaptiveLimiter(InitialLimitOption(1))
+	client := NewClient(&avoidingTransportFactory{}, AdaptiveLimitOption(limiter))
+
+	started := make(chan bool)
+	release := make(chan bool)
+	go client.Invoke(context.Background(), testMethod, nil, func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		started <- true
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Invoke(context.Background(), testMethod, nil, func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+			return nil, nil
+		})
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected the second call to wait for the first")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if limiter.Limit() != 3 {
+		t.Errorf("expected both calls to grow the limit to 3, received %d", limiter.Limit())
+	}
+}
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// Cache stores call results by key, see CachingOption. Implementations must be safe for concurrent
+// use.
+type Cache interface {
+	// Get returns the value stored for key, if it has not expired.
+	Get(key string) (interface{}, bool)
+	// Set stores value for key until ttl has passed.
+	Set(key string, value interface{}, ttl time.Duration)
+	// Delete removes the value stored for key, if any.
+	Delete(key string)
+}
+
+// CachingOption caches the successful results of calls to methods with a CacheTTL in cache, keyed
+// by CallKey. Cached results are shared as is, so callers must not modify them.
+func CachingOption(cache Cache) ClientOption {
+	return func(client *Client) {
+		client.cache = cache
+	}
+}
+
+// cached returns an Invoker serving results from the client's cache, and storing those of invoker
+// in it.
+func (c *Client) cached(invoker Invoker) Invoker {
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		key, err := CallKey(call.Method, call.Args)
+		if err != nil {
+			return invoker(ctx, call)
+		}
+		if result, ok := c.cache.Get(key); ok {
+			return result, nil
+		}
+
+		result, err := invoker(ctx, call)
+		if err == nil {
+			c.cache.Set(key, result, call.Method.CacheTTL)
+		}
+		return result, err
+	}
+}
+
+// Invalidate removes the cached result of calling method with args, if any.
+func (c *Client) Invalidate(method *Method, args thrift.TStruct) error {
+	if c.cache == nil {
+		return nil
+	}
+	key, err := CallKey(method, args)
+	if err != nil {
+		return err
+	}
+	c.cache.Delete(key)
+	return nil
+}
+
+// LRUCache is an in-memory Cache holding a bounded number of values, evicting the least recently
+// used ones first.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  *list.List // of *lruEntry, most recently used first
+	keys     map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewLRUCache returns a new LRUCache holding up to capacity values. A capacity below 1 holds
+// nothing, which turns caching off.
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		entries:  list.New(),
+		keys:     map[string]*list.Element{},
+	}
+}
+
+// Get implements Cache.
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.keys[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set implements Cache.
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	if c.capacity < 1 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if element, ok := c.keys[key]; ok {
+		element.Value = entry
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.keys[key] = c.entries.PushFront(entry)
+	for c.entries.Len() > c.capacity {
+		c.remove(c.entries.Back())
+	}
+}
+
+// Delete implements Cache.
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.keys[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of values held, including expired ones not yet evicted.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+func (c *LRUCache) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.keys, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+func TestClient_Caching(t *testing.T) {
+	cachedMethod := &Method{Service: "TestService", Name: "lookup", CacheTTL: time.Minute}
+	client := NewClient(&memoryTransportFactory{}, CachingOption(NewLRUCache(10)))
+
+	attempts := 0
+	fn := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		attempts++
+		return attempts, nil
+	}
+	call := func(method *Method, id string) interface{} {
+		result, err := client.Invoke(context.Background(), method, &idArgs{ID: id}, fn)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	if first, second := call(cachedMethod, "a"), call(cachedMethod, "a"); first != 1 || second != 1 {
+		t.Errorf("expected the second call to be served from the cache, received %v and %v", first, second)
+	}
+	if result := call(cachedMethod, "b"); result != 2 {
+		t.Errorf("expected a call with different args to miss the cache, received %v", result)
+	}
+	if err := client.Invalidate(cachedMethod, &idArgs{ID: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := call(cachedMethod, "a"); result != 3 {
+		t.Errorf("expected an invalidated call to miss the cache, received %v", result)
+	}
+	if first, second := call(testMethod, "a"), call(testMethod, "a"); first != 4 || second != 5 {
+		t.Errorf("expected methods without a CacheTTL not to be cached, received %v and %v", first, second)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Minute)
+	cache.Get("a")
+	cache.Set("c", 3, time.Minute)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected the least recently used value to be evicted")
+	}
+	if value, ok := cache.Get("a"); !ok || value != 1 {
+		t.Errorf("expected (1, true), received (%v, %v)", value, ok)
+	}
+
+	cache.Set("d", 4, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("d"); ok {
+		t.Error("expected expired values to be missing")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 value left, received %d", cache.Len())
+	}
+
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected deleted values to be missing")
+	}
+}
+
+func TestLRUCache_NoCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		cache := NewLRUCache(capacity)
+		cache.Set("a", 1, time.Minute)
+		if _, ok := cache.Get("a"); ok || cache.Len() != 0 {
+			t.Errorf("expected a cache of capacity %d to hold nothing, received %d values", capacity, cache.Len())
+		}
+	}
+}
@@ -1,18 +1,87 @@
 package rpc
 
 import (
+	"context"
+	"net"
+	"time"
+
 	"git.apache.org/thrift.git/lib/go/thrift"
 	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"golang.org/x/sync/singleflight"
 )
+
 // TransportFactory is an interface for returning a thrift client with opened transport.
 type TransportFactory interface {
 	GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error)
 }
 
+// Method describes a thrift method called through a Client. Generated clients declare one for
+// each wrapped method.
+type Method struct {
+	Service string // The thrift service name.
+	Name    string // The thrift method name.
+
+	// Idempotent methods may be hedged, see HedgingOption.
+	Idempotent bool
+	// ReadOnly methods may be coalesced, see CoalescingOption.
+	ReadOnly bool
+	// CacheTTL is how long results of the method may be cached for, see CachingOption.
+	CacheTTL time.Duration
+
+	// Sensitive lists the args, and fields nested within them, that must not be logged, as
+	// dot-separated paths of thrift names, e.g. "request.password".
+	Sensitive []string
+	// Required lists the required fields nested within the args, as paths like Sensitive. Calls
+	// with any of them unset fail with a ValidationError without being sent. Only required
+	// structs, unions and exceptions can be unset: other required fields have non-pointer go
+	// types, or are written as empty when nil, so they are never reported.
+	Required []string
+}
+
+// Call describes a logical call made through a Client, or a single attempt of one.
+type Call struct {
+	Method  *Method
+	Args    thrift.TStruct // The thrift gen args struct of the call.
+	Attempt uint64         // The zero-based attempt number. Always 0 for call interceptors.
+	Wait    time.Duration  // The time spent backing off before this attempt.
+
+	// Endpoint is the remote address of the attempt's transport, if the transport exposes one like
+	// thrift.TSocket does. It is set once the transport is opened.
+	Endpoint string
+
+	// Headers are sent with the attempt when the protocol supports them, such as the one of
+	// NewTHeaderProtocolFactory. Attempt interceptors may add to them.
+	Headers map[string]string
+
+	// endpoints are those used by the attempts of a hedged call.
+	endpoints *endpointSet
+}
+
+// AttemptFunc performs a single attempt of a call over an opened transport. Generated clients
+// implement it by calling the Apache-generated client.
+type AttemptFunc func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error)
+
+// Invoker performs a call and returns its result.
+type Invoker func(ctx context.Context, call *Call) (interface{}, error)
+
+// Interceptor wraps an Invoker, e.g. to observe or alter calls. Implementations must call next to
+// continue the call.
+type Interceptor func(ctx context.Context, call *Call, next Invoker) (interface{}, error)
+
 // Client is used to implement RPC calls. This should be type-aliased for specific clients.
 type Client struct {
 	TransportFactory TransportFactory
 	Retrier          *retry.Retrier
+
+	interceptors        []Interceptor
+	attemptInterceptors []Interceptor
+	hedger              *hedger
+	coalesced           map[*Method]bool
+	inFlight            singleflight.Group
+	cache               Cache
+	limiters            []limiter
+	methodLimiters      map[*Method][]limiter
+	multiplexedService  string
 }
 
 // NewClient creates a new Client.
@@ -39,3 +108,166 @@ func RetrierOption(retrier *retry.Retrier) ClientOption {
 		client.Retrier = retrier
 	}
 }
+
+// InterceptorOption adds an Interceptor around each logical call, including all of its attempts.
+// Interceptors run in the order they are added.
+func InterceptorOption(interceptor Interceptor) ClientOption {
+	return func(client *Client) {
+		client.interceptors = append(client.interceptors, interceptor)
+	}
+}
+
+// AttemptInterceptorOption adds an Interceptor around each attempt made by the Retrier.
+// Interceptors run in the order they are added.
+func AttemptInterceptorOption(interceptor Interceptor) ClientOption {
+	return func(client *Client) {
+		client.attemptInterceptors = append(client.attemptInterceptors, interceptor)
+	}
+}
+
+// MultiplexedOption wraps the client's protocols with thrift.TMultiplexedProtocol, so calls reach
+// the processor registered for service on servers multiplexing several services. An empty service
+// turns multiplexing off.
+func MultiplexedOption(service string) ClientOption {
+	return func(client *Client) {
+		client.multiplexedService = service
+	}
+}
+
+// Invoke performs a call to method with args through the client's interceptors and Retrier, calling
+// fn with a new transport for every attempt. It returns the result of the last attempt.
+func (c *Client) Invoke(ctx context.Context, method *Method, args thrift.TStruct, fn AttemptFunc) (interface{}, error) {
+	invoker := c.retry(fn)
+	// method limits are taken first, so that calls waiting on a saturated method don't hold the
+	// client-wide slots other methods need
+	if len(c.limiters) > 0 {
+		invoker = limit(c.limiters, invoker)
+	}
+	if limiters := c.methodLimiters[method]; len(limiters) > 0 {
+		invoker = limit(limiters, invoker)
+	}
+	if c.coalesced[method] {
+		invoker = c.coalesce(invoker)
+	}
+	if c.cache != nil && method.CacheTTL > 0 {
+		invoker = c.cached(invoker)
+	}
+	if len(method.Required) > 0 {
+		invoker = validated(invoker)
+	}
+	return chain(c.interceptors, invoker)(ctx, &Call{Method: method, Args: args})
+}
+
+// retry returns an Invoker making attempts with fn until the Retrier gives up.
+func (c *Client) retry(fn AttemptFunc) Invoker {
+	attempt := chain(c.attemptInterceptors, c.attempt(fn))
+	var hedged Invoker
+	if c.hedger != nil {
+		hedged = c.hedger.retry(c.Retrier, attempt)
+	}
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		if hedged != nil && call.Method.Idempotent {
+			return hedged(ctx, call)
+		}
+
+		var result interface{}
+		var wait time.Duration
+		err := c.Retrier.DoNotify(
+			func(i uint64) (err error) {
+				result, err = attempt(ctx, &Call{
+					Method:  call.Method,
+					Args:    call.Args,
+					Attempt: i,
+					Wait:    wait,
+					Headers: map[string]string{},
+				})
+				return err
+			},
+			func(i uint64, backoff time.Duration) {
+				wait = backoff
+			},
+		)
+		return result, err
+	}
+}
+
+// attempt returns an Invoker calling fn with a new transport.
+func (c *Client) attempt(fn AttemptFunc) Invoker {
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		transport, protocolFactory, err := c.getTransport(call)
+		if err != nil {
+			return nil, err
+		}
+		defer transport.Close()
+
+		if addressable, ok := transport.(addressable); ok && addressable.Addr() != nil {
+			call.Endpoint = addressable.Addr().String()
+			call.endpoints.add(call.Endpoint)
+		}
+		if len(call.Headers) > 0 {
+			protocolFactory = &headerProtocolFactory{TProtocolFactory: protocolFactory, headers: call.Headers}
+		}
+		if c.multiplexedService != "" {
+			protocolFactory = &multiplexedProtocolFactory{TProtocolFactory: protocolFactory, service: c.multiplexedService}
+		}
+		return fn(transport, protocolFactory)
+	}
+}
+
+// addressable is implemented by transports connected to a remote address, such as thrift.TSocket.
+type addressable interface {
+	Addr() net.Addr
+}
+
+// headerWriter is implemented by protocols that send headers, such as THeaderProtocol.
+type headerWriter interface {
+	SetWriteHeader(key, value string)
+}
+
+// headerProtocolFactory sets headers on the protocols it returns, if they support them.
+type headerProtocolFactory struct {
+	thrift.TProtocolFactory
+	headers map[string]string
+}
+
+func (f *headerProtocolFactory) GetProtocol(transport thrift.TTransport) thrift.TProtocol {
+	protocol := f.TProtocolFactory.GetProtocol(transport)
+	if writer, ok := protocol.(headerWriter); ok {
+		for key, value := range f.headers {
+			writer.SetWriteHeader(key, value)
+		}
+	}
+	return protocol
+}
+
+// multiplexedProtocolFactory wraps the protocols it returns with thrift.TMultiplexedProtocol.
+type multiplexedProtocolFactory struct {
+	thrift.TProtocolFactory
+	service string
+}
+
+func (f *multiplexedProtocolFactory) GetProtocol(transport thrift.TTransport) thrift.TProtocol {
+	return thrift.NewTMultiplexedProtocol(f.TProtocolFactory.GetProtocol(transport), f.service)
+}
+
+// getTransport returns a transport for an attempt, avoiding the endpoints used by the other attempts
+// of a hedged call when possible.
+func (c *Client) getTransport(call *Call) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	if factory, ok := c.TransportFactory.(AvoidingTransportFactory); ok {
+		if endpoints := call.endpoints.list(); len(endpoints) > 0 {
+			return factory.GetTransportAvoiding(endpoints)
+		}
+	}
+	return c.TransportFactory.GetTransport()
+}
+
+// chain wraps invoker in interceptors, so that the first interceptor runs first.
+func chain(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, call *Call) (interface{}, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return invoker
+}
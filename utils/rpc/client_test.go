@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc/rpctest"
+)
+
+// memoryTransportFactory returns memory buffers, counting how many it handed out.
+type memoryTransportFactory struct {
+	transports int
+}
+
+func (f *memoryTransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	f.transports++
+	return thrift.NewTMemoryBuffer(), thrift.NewTBinaryProtocolFactoryDefault(), nil
+}
+
+var testMethod = &Method{Service: "TestService", Name: "test"}
+
+func TestClient_Invoke(t *testing.T) {
+	var events []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, call *Call, next Invoker) (interface{}, error) {
+			events = append(events, name)
+			if call.Method != testMethod {
+				t.Errorf("%s: expected call to carry its method", name)
+			}
+			return next(ctx, call)
+		}
+	}
+
+	factory := &memoryTransportFactory{}
+	client := NewClient(
+		factory,
+		RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(3), retry.BackoffOption(retry.NoopBackoff))),
+		InterceptorOption(record("call1")),
+		InterceptorOption(record("call2")),
+		AttemptInterceptorOption(func(ctx context.Context, call *Call, next Invoker) (interface{}, error) {
+			events = append(events, "attempt")
+			if call.Attempt != uint64(factory.transports) {
+				t.Errorf("expected attempt %d, received %d", factory.transports, call.Attempt)
+			}
+			return next(ctx, call)
+		}),
+	)
+
+	result, err := client.Invoke(context.Background(), testMethod, nil,
+		func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+			if factory.transports < 2 {
+				return nil, errors.New("err")
+			}
+			return "ok", nil
+		})
+	if err != nil || result != "ok" {
+		t.Errorf("expected (ok, nil), received (%v, %v)", result, err)
+	}
+
+	expected := []string{"call1", "call2", "attempt", "attempt"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, received %v", expected, events)
+	}
+}
+
+// headerProcessor replies to every call with an empty struct, recording the headers it was sent.
+type headerProcessor struct {
+	headers map[string]string
+}
+
+func (p *headerProcessor) Process(in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin()
+	if err != nil {
+		return false, err
+	}
+	p.headers = in.(*THeaderProtocol).ReadHeaders()
+	in.Skip(thrift.STRUCT)
+	in.ReadMessageEnd()
+
+	out.WriteMessageBegin(name, thrift.REPLY, seqID)
+	writeEmptyStruct(out)
+	out.WriteMessageEnd()
+	return true, out.Flush()
+}
+
+func writeEmptyStruct(p thrift.TProtocol) {
+	p.WriteStructBegin("empty")
+	p.WriteFieldStop()
+	p.WriteStructEnd()
+}
+
+// emptyCall performs a call with empty args and result the way an Apache-generated client would.
+func emptyCall(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+	oprot, iprot := protocolFactory.GetProtocol(transport), protocolFactory.GetProtocol(transport)
+	oprot.WriteMessageBegin("test", thrift.CALL, 1)
+	writeEmptyStruct(oprot)
+	oprot.WriteMessageEnd()
+	if err := oprot.Flush(); err != nil {
+		return nil, err
+	}
+	if _, _, _, err := iprot.ReadMessageBegin(); err != nil {
+		return nil, err
+	}
+	return nil, iprot.Skip(thrift.STRUCT)
+}
+
+func TestClient_InvokeHeaders(t *testing.T) {
+	processor := &headerProcessor{}
+	client := NewClient(
+		rpctest.NewTransportFactory(processor, rpctest.ProtocolFactoryOption(NewTHeaderProtocolFactory())),
+		AttemptInterceptorOption(func(ctx context.Context, call *Call, next Invoker) (interface{}, error) {
+			call.Headers["request-id"] = "abc"
+			return next(ctx, call)
+		}),
+		MultiplexedOption("TestService"),
+	)
+
+	if _, err := client.Invoke(context.Background(), testMethod, nil, emptyCall); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if processor.headers["request-id"] != "abc" {
+		t.Errorf("expected the server to receive the request-id header, received %v", processor.headers)
+	}
+}
+
+func TestClient_InvokeMultiplexed(t *testing.T) {
+	messageName := func(client *Client) string {
+		result, err := client.Invoke(context.Background(), testMethod, nil,
+			func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+				if err := protocolFactory.GetProtocol(transport).WriteMessageBegin("test", thrift.CALL, 1); err != nil {
+					return nil, err
+				}
+				name, _, _, err := thrift.NewTBinaryProtocolTransport(transport).ReadMessageBegin()
+				return name, err
+			})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result.(string)
+	}
+
+	if name := messageName(NewClient(&memoryTransportFactory{})); name != "test" {
+		t.Errorf("expected message test, received %q", name)
+	}
+	client := NewClient(&memoryTransportFactory{}, MultiplexedOption("TestService"), MultiplexedOption("Other"))
+	if name := messageName(client); name != "Other:test" {
+		t.Errorf("expected message Other:test, received %q", name)
+	}
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// CoalescingOption coalesces identical concurrent calls to the given methods: while a call is in
+// flight, calls to the same method with the same serialized args wait for it and share its result,
+// including its error, instead of making their own. Results are shared as is, so callers must not
+// modify them. Methods that are not ReadOnly are never coalesced.
+//
+// Args containing maps or sets may serialize differently from call to call, in which case their
+// calls are not coalesced.
+func CoalescingOption(methods ...*Method) ClientOption {
+	return func(client *Client) {
+		if client.coalesced == nil {
+			client.coalesced = map[*Method]bool{}
+		}
+		for _, method := range methods {
+			if method.ReadOnly {
+				client.coalesced[method] = true
+			}
+		}
+	}
+}
+
+// coalesce returns an Invoker sharing the in-flight result of invoker between identical calls.
+// The shared call runs without the cancelation and deadline of the call that started it, so that
+// the calls waiting for it are not failed when that call gives up, but with its values, such as its
+// trace span. Waiting calls, including the one that started it, give up when their own ctx is done.
+func (c *Client) coalesce(invoker Invoker) Invoker {
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		key, err := CallKey(call.Method, call.Args)
+		if err != nil {
+			return invoker(ctx, call)
+		}
+
+		shared := context.WithoutCancel(ctx)
+		results := c.inFlight.DoChan(key, func() (interface{}, error) {
+			return invoker(shared, call)
+		})
+		select {
+		case result := <-results:
+			return result.Val, result.Err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// CallKey returns a key identifying calls to method with args, built from the binary serialization
+// of args.
+func CallKey(method *Method, args thrift.TStruct) (string, error) {
+	key := method.Service + "." + method.Name
+	if args == nil {
+		return key, nil
+	}
+	serialized, err := thrift.NewTSerializer().Write(args)
+	if err != nil {
+		return "", err
+	}
+	return key + "\x00" + string(serialized), nil
+}
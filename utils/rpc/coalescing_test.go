@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// idArgs is a minimal thrift args struct with a single string field.
+type idArgs struct {
+	ID string
+}
+
+func (a *idArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("args")
+	p.WriteFieldBegin("id", thrift.STRING, 1)
+	p.WriteString(a.ID)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+	return p.WriteStructEnd()
+}
+
+func (a *idArgs) Read(p thrift.TProtocol) error {
+	return nil
+}
+
+func TestClient_Coalescing(t *testing.T) {
+	readOnly := &Method{Service: "TestService", Name: "get", ReadOnly: true}
+	client := NewClient(&avoidingTransportFactory{}, CoalescingOption(readOnly, testMethod))
+
+	// attempts calls method concurrently once per id, returning how many attempts were made.
+	attempts := func(method *Method, ids ...string) int32 {
+		var total int32
+		slow := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+			atomic.AddInt32(&total, 1)
+			time.Sleep(20 * time.Millisecond)
+			return "ok", nil
+		}
+		var wg sync.WaitGroup
+		for _, id := range ids {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				result, err := client.Invoke(context.Background(), method, &idArgs{ID: id}, slow)
+				if err != nil || result != "ok" {
+					t.Errorf("expected (ok, nil), received (%v, %v)", result, err)
+				}
+			}(id)
+		}
+		wg.Wait()
+		return total
+	}
+
+	if total := attempts(readOnly, "a", "a", "a", "a"); total != 1 {
+		t.Errorf("expected identical calls to share 1 attempt, received %d", total)
+	}
+	if total := attempts(readOnly, "a", "b"); total != 2 {
+		t.Errorf("expected calls with different args to make 2 attempts, received %d", total)
+	}
+	if total := attempts(testMethod, "a", "a"); total != 2 {
+		t.Errorf("expected calls to a method that is not read-only to make 2 attempts, received %d", total)
+	}
+}
+
+func TestClient_CoalescingCanceled(t *testing.T) {
+	readOnly := &Method{Service: "TestService", Name: "get", ReadOnly: true}
+	started, release := make(chan bool, 1), make(chan bool)
+	client := NewClient(
+		&avoidingTransportFactory{},
+		CoalescingOption(readOnly),
+		AttemptInterceptorOption(func(ctx context.Context, call *Call, next Invoker) (interface{}, error) {
+			started <- true
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return next(ctx, call)
+		}),
+	)
+	var attempts int32
+	attempt := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		atomic.AddInt32(&attempts, 1)
+		return "ok", nil
+	}
+
+	// the first call starts the shared attempt and gives up while the second one waits for it
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := client.Invoke(ctx, readOnly, &idArgs{ID: "a"}, attempt)
+		first <- err
+	}()
+	<-started
+	second := make(chan error)
+	go func() {
+		result, err := client.Invoke(context.Background(), readOnly, &idArgs{ID: "a"}, attempt)
+		if err == nil && result != "ok" {
+			t.Errorf("expected ok, received %v", result)
+		}
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("expected the first call to be canceled, received %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("expected the second call to succeed, received %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected the calls to share 1 attempt, received %d", attempts)
+	}
+}
+
+func TestCallKey(t *testing.T) {
+	a1, err := CallKey(testMethod, &idArgs{ID: "a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a2, _ := CallKey(testMethod, &idArgs{ID: "a"})
+	b, _ := CallKey(testMethod, &idArgs{ID: "b"})
+	if a1 != a2 || a1 == b {
+		t.Error("expected keys to be equal exactly when the args are")
+	}
+}
@@ -0,0 +1,38 @@
+package rpc
+
+import "git.apache.org/thrift.git/lib/go/thrift"
+
+// Error classes returned by ErrorClass.
+const (
+	ErrorClassTransport   = "transport"   // The connection failed or timed out.
+	ErrorClassProtocol    = "protocol"    // A message could not be encoded or decoded.
+	ErrorClassApplication = "application" // The server failed with a TApplicationException.
+	ErrorClassException   = "exception"   // The server returned an exception declared in the IDL.
+	ErrorClassRejected    = "rejected"    // A client-side limit rejected the call, see RejectedError.
+	ErrorClassInvalid     = "invalid"     // The args were missing required fields, see ValidationError.
+	ErrorClassUnknown     = "unknown"     // Any other error.
+)
+
+// ErrorClass returns a short, stable label for the kind of error a call returned, suitable for
+// metrics and logs. It returns the empty string for a nil error.
+func ErrorClass(err error) string {
+	switch err.(type) {
+	case nil:
+		return ""
+	case *RejectedError:
+		return ErrorClassRejected
+	case *ValidationError:
+		return ErrorClassInvalid
+	case thrift.TTransportException:
+		return ErrorClassTransport
+	case thrift.TProtocolException:
+		return ErrorClassProtocol
+	case thrift.TApplicationException:
+		return ErrorClassApplication
+	case thrift.TStruct:
+		// Apache-generated exceptions are structs; TApplicationException is not.
+		return ErrorClassException
+	default:
+		return ErrorClassUnknown
+	}
+}
@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+func TestErrorClass(t *testing.T) {
+	errorClasses := []struct {
+		err      error
+		expected string
+	}{
+		{nil, ""},
+		{thrift.NewTTransportException(thrift.TIMED_OUT, "timeout"), ErrorClassTransport},
+		{thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, errors.New("bad")), ErrorClassProtocol},
+		{thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "boom"), ErrorClassApplication},
+		{&ValidationError{Method: testMethod, Field: "request.id"}, ErrorClassInvalid},
+		{errors.New("other"), ErrorClassUnknown},
+	}
+
+	for _, tc := range errorClasses {
+		if actual := ErrorClass(tc.err); actual != tc.expected {
+			t.Errorf("ErrorClass(%v) => %q, want %q", tc.err, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package fault provides an rpc.TransportFactory that injects failures into calls, so that retry,
+// timeout and breaker behaviour can be exercised deterministically in tests.
+package fault
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+const (
+	minGarbleLength = 16
+	maxGarbleLength = 64
+)
+
+// Rule describes the faults injected into calls of a method. Every probability is in [0, 1] and is
+// evaluated independently for each call, in the order refusal, latency, reset, garble. A refused call
+// never reaches the server, and a reset takes precedence over a garbled response.
+type Rule struct {
+	// RefuseProbability is the chance the call fails as if the connection was refused.
+	RefuseProbability float64
+	// LatencyProbability is the chance Latency is added before the request is sent.
+	LatencyProbability float64
+	Latency            time.Duration
+	// ResetProbability is the chance the connection is reset after the request is sent.
+	ResetProbability float64
+	// GarbleProbability is the chance the response is replaced with random bytes.
+	GarbleProbability float64
+}
+
+// TransportFactory wraps an rpc.TransportFactory and injects faults into the calls made over the
+// transports it returns. Faults are decided per call once the request is flushed, because that is
+// the first point the method name is known.
+type TransportFactory struct {
+	factory     rpc.TransportFactory
+	defaultRule Rule
+	methodRules map[string]Rule
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Option is an optional argument to NewTransportFactory.
+type Option func(f *TransportFactory)
+
+// RuleOption sets the Rule for methods without a MethodRuleOption.
+// Defaults to injecting no faults.
+func RuleOption(rule Rule) Option {
+	return func(f *TransportFactory) {
+		f.defaultRule = rule
+	}
+}
+
+// MethodRuleOption sets the Rule for a single method, by its thrift name.
+func MethodRuleOption(method string, rule Rule) Option {
+	return func(f *TransportFactory) {
+		f.methodRules[method] = rule
+	}
+}
+
+// SeedOption seeds the random source that decides faults. Calls made sequentially with the same
+// seed see the same faults. Defaults to a time based seed.
+func SeedOption(seed int64) Option {
+	return func(f *TransportFactory) {
+		f.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// NewTransportFactory returns a TransportFactory injecting faults into calls made through factory.
+func NewTransportFactory(factory rpc.TransportFactory, options ...Option) *TransportFactory {
+	f := &TransportFactory{
+		factory:     factory,
+		methodRules: map[string]Rule{},
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, option := range options {
+		option(f)
+	}
+
+	return f
+}
+
+// GetTransport returns a transport from the wrapped factory that injects faults.
+func (f *TransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	return f.wrap(f.factory.GetTransport())
+}
+
+// GetTransportAvoiding is like GetTransport, but passes endpoints on to the wrapped factory if it
+// is an rpc.AvoidingTransportFactory, so hedged calls still avoid them.
+func (f *TransportFactory) GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	if factory, ok := f.factory.(rpc.AvoidingTransportFactory); ok {
+		return f.wrap(factory.GetTransportAvoiding(endpoints))
+	}
+	return f.GetTransport()
+}
+
+func (f *TransportFactory) wrap(trans thrift.TTransport, protocolFactory thrift.TProtocolFactory, err error) (
+	thrift.TTransport, thrift.TProtocolFactory, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return &transport{TTransport: trans, factory: f, protocolFactory: protocolFactory}, protocolFactory, nil
+}
+
+// decision is the set of faults chosen for a single call.
+type decision struct {
+	refuse  bool
+	latency time.Duration
+	reset   bool
+	garble  []byte
+}
+
+// decide draws the faults for a call to method.
+func (f *TransportFactory) decide(method string) decision {
+	rule, ok := f.methodRules[method]
+	if !ok {
+		rule = f.defaultRule
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Always draw every value so the sequence of decisions only depends on the sequence of calls.
+	var d decision
+	d.refuse = f.rand.Float64() < rule.RefuseProbability
+	if f.rand.Float64() < rule.LatencyProbability {
+		d.latency = rule.Latency
+	}
+	d.reset = f.rand.Float64() < rule.ResetProbability
+	garble := f.rand.Float64() < rule.GarbleProbability
+	garbage := make([]byte, minGarbleLength+f.rand.Intn(maxGarbleLength-minGarbleLength))
+	f.rand.Read(garbage)
+	if garble && !d.reset {
+		// A leading zero byte is invalid as a message header for the binary, compact and JSON
+		// protocols, so the client fails fast instead of trying to read a huge message.
+		garbage[0] = 0
+		d.garble = garbage
+	}
+	return d
+}
+
+// transport buffers each request until it is flushed, so the method name can be decoded before
+// deciding which faults to inject.
+type transport struct {
+	thrift.TTransport
+	factory         *TransportFactory
+	protocolFactory thrift.TProtocolFactory
+
+	request  bytes.Buffer
+	decision decision
+}
+
+// Write buffers the request until Flush.
+func (t *transport) Write(buf []byte) (int, error) {
+	return t.request.Write(buf)
+}
+
+// Flush decides the faults for the buffered request and forwards it unless it is refused.
+func (t *transport) Flush() error {
+	defer t.request.Reset()
+
+	t.decision = t.factory.decide(t.method())
+	if t.decision.refuse {
+		return thrift.NewTTransportException(thrift.NOT_OPEN, "fault: connection refused")
+	}
+	if t.decision.latency > 0 {
+		time.Sleep(t.decision.latency)
+	}
+	if _, err := t.TTransport.Write(t.request.Bytes()); err != nil {
+		return err
+	}
+	return t.TTransport.Flush()
+}
+
+// Read returns the response, unless the call was reset or its response garbled.
+func (t *transport) Read(buf []byte) (int, error) {
+	switch {
+	case t.decision.reset:
+		t.TTransport.Close()
+		return 0, thrift.NewTTransportException(thrift.UNKNOWN_TRANSPORT_EXCEPTION, "fault: connection reset by peer")
+	case t.decision.garble != nil:
+		if len(t.decision.garble) == 0 {
+			return 0, thrift.NewTTransportExceptionFromError(io.EOF)
+		}
+		n := copy(buf, t.decision.garble)
+		t.decision.garble = t.decision.garble[n:]
+		return n, nil
+	default:
+		return t.TTransport.Read(buf)
+	}
+}
+
+// RemainingBytes reports the exact size of a garbled response, so protocols reject bogus lengths
+// instead of trying to read them.
+func (t *transport) RemainingBytes() uint64 {
+	if t.decision.garble != nil {
+		return uint64(len(t.decision.garble))
+	}
+	return t.TTransport.RemainingBytes()
+}
+
+// Addr returns the remote address of the wrapped transport, if it exposes one, so calls still
+// report their endpoint.
+func (t *transport) Addr() net.Addr {
+	if addressable, ok := t.TTransport.(interface{ Addr() net.Addr }); ok {
+		return addressable.Addr()
+	}
+	return nil
+}
+
+// method decodes the thrift method name from the buffered request, without any multiplexing prefix.
+func (t *transport) method() string {
+	buffer := thrift.NewTMemoryBuffer()
+	buffer.Write(t.request.Bytes())
+	name, _, _, err := t.protocolFactory.GetProtocol(buffer).ReadMessageBegin()
+	if err != nil {
+		return ""
+	}
+	return name[strings.LastIndex(name, thrift.MULTIPLEXED_SEPARATOR)+1:]
+}
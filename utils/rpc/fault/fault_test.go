@@ -0,0 +1,152 @@
+package fault
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc/rpctest"
+)
+
+// pingProcessor replies to every call with an empty result struct.
+type pingProcessor struct{}
+
+func (pingProcessor) Process(in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin()
+	if err != nil {
+		return false, err
+	}
+	in.Skip(thrift.STRUCT)
+	in.ReadMessageEnd()
+
+	out.WriteMessageBegin(name, thrift.REPLY, seqID)
+	writeEmptyStruct(out)
+	out.WriteMessageEnd()
+	return true, out.Flush()
+}
+
+func writeEmptyStruct(p thrift.TProtocol) {
+	p.WriteStructBegin("empty")
+	p.WriteFieldStop()
+	p.WriteStructEnd()
+}
+
+// call performs a single empty call to method the way an Apache-generated client would.
+func call(factory rpc.TransportFactory, method string) error {
+	transport, protocolFactory, err := factory.GetTransport()
+	if err != nil {
+		return err
+	}
+	defer transport.Close()
+
+	protocol := protocolFactory.GetProtocol(transport)
+	protocol.WriteMessageBegin(method, thrift.CALL, 1)
+	writeEmptyStruct(protocol)
+	protocol.WriteMessageEnd()
+	if err = protocol.Flush(); err != nil {
+		return err
+	}
+	if _, _, _, err = protocol.ReadMessageBegin(); err != nil {
+		return err
+	}
+	return protocol.Skip(thrift.STRUCT)
+}
+
+func TestTransportFactory_NoFaults(t *testing.T) {
+	factory := NewTransportFactory(rpctest.NewTransportFactory(pingProcessor{}))
+	for i := 0; i < 10; i++ {
+		if err := call(factory, "ping"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestTransportFactory_Faults(t *testing.T) {
+	faults := []struct {
+		name string
+		rule Rule
+	}{
+		{"refuse", Rule{RefuseProbability: 1}},
+		{"reset", Rule{ResetProbability: 1}},
+		{"garble", Rule{GarbleProbability: 1}},
+	}
+
+	for _, fault := range faults {
+		factory := NewTransportFactory(
+			rpctest.NewTransportFactory(pingProcessor{}),
+			MethodRuleOption("ping", fault.rule),
+		)
+		if err := call(factory, "ping"); err == nil {
+			t.Errorf("%s: expected ping to fail", fault.name)
+		}
+		if err := call(factory, "pong"); err != nil {
+			t.Errorf("%s: expected pong to succeed, received %v", fault.name, err)
+		}
+	}
+}
+
+func TestTransportFactory_Latency(t *testing.T) {
+	factory := NewTransportFactory(
+		rpctest.NewTransportFactory(pingProcessor{}),
+		RuleOption(Rule{LatencyProbability: 1, Latency: 50 * time.Millisecond}),
+	)
+	start := time.Now()
+	if err := call(factory, "ping"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("expected call to be delayed")
+	}
+}
+
+func TestTransportFactory_Seed(t *testing.T) {
+	outcomes := func() []bool {
+		factory := NewTransportFactory(
+			rpctest.NewTransportFactory(pingProcessor{}),
+			RuleOption(Rule{RefuseProbability: 0.5}),
+			SeedOption(42),
+		)
+		results := make([]bool, 20)
+		for i := range results {
+			results[i] = call(factory, "ping") == nil
+		}
+		return results
+	}
+
+	first, second := outcomes(), outcomes()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected identical outcomes for identical seeds, call %d differs", i)
+		}
+	}
+}
+
+// avoidingFactory is an rpc.AvoidingTransportFactory recording the endpoints it was asked to avoid.
+type avoidingFactory struct {
+	*rpctest.TransportFactory
+	avoided []string
+}
+
+func (f *avoidingFactory) GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	f.avoided = endpoints
+	return f.GetTransport()
+}
+
+func TestTransportFactory_Endpoints(t *testing.T) {
+	factory := &avoidingFactory{TransportFactory: rpctest.NewTransportFactory(pingProcessor{})}
+	var faulty rpc.AvoidingTransportFactory = NewTransportFactory(factory)
+	transport, _, err := faulty.GetTransportAvoiding([]string{"10.0.0.1:9090"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer transport.Close()
+
+	if len(factory.avoided) != 1 || factory.avoided[0] != "10.0.0.1:9090" {
+		t.Errorf("expected the endpoint to be avoided by the wrapped factory, received %v", factory.avoided)
+	}
+	if addressable, ok := transport.(interface{ Addr() net.Addr }); !ok || addressable.Addr() == nil {
+		t.Error("expected the transport to expose the address of the wrapped one")
+	}
+}
@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ThriftFieldName returns the thrift name of a thrift gen struct field, from its
+// `thrift:"name,id"` tag. It returns the empty string for fields that are not thrift fields.
+func ThriftFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	tag := field.Tag.Get("thrift")
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// thriftField returns the field of the thrift gen struct value with the thrift name name.
+func thriftField(value reflect.Value, name string) (reflect.Value, bool) {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		if ThriftFieldName(structType.Field(i)) == name {
+			return value.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestThriftFieldName(t *testing.T) {
+	type args struct {
+		ID       int64  `thrift:"id,1,required" db:"id" json:"id"`
+		Name     string `thrift:"name,2"`
+		Untagged string
+		hidden   string `thrift:"hidden,3"`
+	}
+	structType := reflect.TypeOf(args{})
+
+	expected := []string{"id", "name", "", ""}
+	for i, name := range expected {
+		if actual := ThriftFieldName(structType.Field(i)); actual != name {
+			t.Errorf("ThriftFieldName(%s) => %q, want %q", structType.Field(i).Name, actual, name)
+		}
+	}
+
+	value := reflect.ValueOf(args{Name: "a"})
+	if field, ok := thriftField(value, "name"); !ok || field.String() != "a" {
+		t.Errorf("expected thriftField to find name, received (%v, %v)", field, ok)
+	}
+	if _, ok := thriftField(value, "hidden"); ok {
+		t.Error("expected thriftField to skip unexported fields")
+	}
+}
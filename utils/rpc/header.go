@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// THeader framing, as implemented by THeaderTransport in later thrift releases.
+const (
+	headerMagic        = 0x0fff
+	headerProtocolID   = 0 // the binary protocol, the only one supported
+	headerInfoKeyValue = 1
+	maxHeaderFrameSize = 16384000
+)
+
+// NewTHeaderProtocolFactory returns a factory of THeaderProtocols, which send the headers of calls
+// to servers speaking the THeader protocol. The pinned thrift library has no header protocol of
+// its own; this one interoperates with THeaderProtocol of later thrift releases using the binary
+// protocol without transforms.
+func NewTHeaderProtocolFactory() thrift.TProtocolFactory {
+	return tHeaderProtocolFactory{}
+}
+
+type tHeaderProtocolFactory struct{}
+
+func (tHeaderProtocolFactory) GetProtocol(transport thrift.TTransport) thrift.TProtocol {
+	return NewTHeaderProtocol(transport)
+}
+
+// THeaderProtocol is the binary protocol over a THeaderTransport.
+type THeaderProtocol struct {
+	thrift.TProtocol
+	transport *THeaderTransport
+}
+
+// NewTHeaderProtocol returns a THeaderProtocol over transport, wrapping it in a THeaderTransport
+// unless it is one.
+func NewTHeaderProtocol(transport thrift.TTransport) *THeaderProtocol {
+	headerTransport, ok := transport.(*THeaderTransport)
+	if !ok {
+		headerTransport = NewTHeaderTransport(transport)
+	}
+	return &THeaderProtocol{
+		TProtocol: thrift.NewTBinaryProtocol(headerTransport, false, true),
+		transport: headerTransport,
+	}
+}
+
+// SetWriteHeader sets a header sent with the following messages.
+func (p *THeaderProtocol) SetWriteHeader(key, value string) {
+	p.transport.SetWriteHeader(key, value)
+}
+
+// ReadHeaders returns the headers of the last message read.
+func (p *THeaderProtocol) ReadHeaders() map[string]string {
+	return p.transport.ReadHeaders()
+}
+
+// WriteMessageBegin passes seqID on to the frame of the message.
+func (p *THeaderProtocol) WriteMessageBegin(name string, typeID thrift.TMessageType, seqID int32) error {
+	p.transport.seqID = seqID
+	return p.TProtocol.WriteMessageBegin(name, typeID, seqID)
+}
+
+// THeaderTransport frames the messages written to a transport with headers, and reads framed
+// messages and their headers from it.
+type THeaderTransport struct {
+	transport    thrift.TTransport
+	writeHeaders map[string]string
+	readHeaders  map[string]string
+	seqID        int32
+
+	writeBuffer bytes.Buffer
+	readBuffer  bytes.Reader
+}
+
+// NewTHeaderTransport returns a THeaderTransport over transport.
+func NewTHeaderTransport(transport thrift.TTransport) *THeaderTransport {
+	return &THeaderTransport{
+		transport:    transport,
+		writeHeaders: map[string]string{},
+		readHeaders:  map[string]string{},
+	}
+}
+
+// SetWriteHeader sets a header sent with the following frames.
+func (t *THeaderTransport) SetWriteHeader(key, value string) {
+	t.writeHeaders[key] = value
+}
+
+// ReadHeaders returns the headers of the last frame read.
+func (t *THeaderTransport) ReadHeaders() map[string]string {
+	return t.readHeaders
+}
+
+// Open opens the underlying transport.
+func (t *THeaderTransport) Open() error {
+	return t.transport.Open()
+}
+
+// IsOpen returns whether the underlying transport is open.
+func (t *THeaderTransport) IsOpen() bool {
+	return t.transport.IsOpen()
+}
+
+// Close closes the underlying transport.
+func (t *THeaderTransport) Close() error {
+	return t.transport.Close()
+}
+
+// Read reads from the current frame, reading the next frame once it is used up.
+func (t *THeaderTransport) Read(buf []byte) (int, error) {
+	if t.readBuffer.Len() == 0 {
+		if err := t.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	return t.readBuffer.Read(buf)
+}
+
+// Write buffers buf until Flush.
+func (t *THeaderTransport) Write(buf []byte) (int, error) {
+	return t.writeBuffer.Write(buf)
+}
+
+// Flush writes the buffered bytes as a frame, along with the write headers.
+func (t *THeaderTransport) Flush() error {
+	defer t.writeBuffer.Reset()
+
+	var header bytes.Buffer
+	writeUvarint(&header, headerProtocolID)
+	writeUvarint(&header, 0) // no transforms
+	if len(t.writeHeaders) > 0 {
+		keys := make([]string, 0, len(t.writeHeaders))
+		for key := range t.writeHeaders {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeUvarint(&header, headerInfoKeyValue)
+		writeUvarint(&header, uint64(len(keys)))
+		for _, key := range keys {
+			writeHeaderString(&header, key)
+			writeHeaderString(&header, t.writeHeaders[key])
+		}
+	}
+	for header.Len()%4 != 0 {
+		header.WriteByte(0)
+	}
+
+	frame := make([]byte, 14, 14+header.Len()+t.writeBuffer.Len())
+	binary.BigEndian.PutUint32(frame[0:], uint32(10+header.Len()+t.writeBuffer.Len()))
+	binary.BigEndian.PutUint16(frame[4:], headerMagic)
+	binary.BigEndian.PutUint16(frame[6:], 0) // no flags
+	binary.BigEndian.PutUint32(frame[8:], uint32(t.seqID))
+	binary.BigEndian.PutUint16(frame[12:], uint16(header.Len()/4))
+	frame = append(append(frame, header.Bytes()...), t.writeBuffer.Bytes()...)
+	if _, err := t.transport.Write(frame); err != nil {
+		return thrift.NewTTransportExceptionFromError(err)
+	}
+	return t.transport.Flush()
+}
+
+// RemainingBytes returns the bytes left in the current frame, or the largest possible size between
+// frames.
+func (t *THeaderTransport) RemainingBytes() uint64 {
+	if t.readBuffer.Len() > 0 {
+		return uint64(t.readBuffer.Len())
+	}
+	return ^uint64(0)
+}
+
+// readFrame reads the next frame, replacing the read headers with its own.
+func (t *THeaderTransport) readFrame() error {
+	var size [4]byte
+	if _, err := io.ReadFull(t.transport, size[:]); err != nil {
+		return thrift.NewTTransportExceptionFromError(err)
+	}
+	frameSize := binary.BigEndian.Uint32(size[:])
+	if frameSize < 10 || frameSize > maxHeaderFrameSize {
+		return thrift.NewTProtocolExceptionWithType(thrift.SIZE_LIMIT,
+			fmt.Errorf("THeader frame size %d out of range", frameSize))
+	}
+	frame := make([]byte, frameSize)
+	if _, err := io.ReadFull(t.transport, frame); err != nil {
+		return thrift.NewTTransportExceptionFromError(err)
+	}
+
+	if binary.BigEndian.Uint16(frame) != headerMagic {
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, errors.New("not a THeader frame"))
+	}
+	headerSize := 4 * int(binary.BigEndian.Uint16(frame[8:]))
+	if 10+headerSize > len(frame) {
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, errors.New("THeader header size out of range"))
+	}
+	headers, err := readHeaderInfo(bytes.NewReader(frame[10 : 10+headerSize]))
+	if err != nil {
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, err)
+	}
+	t.readHeaders = headers
+	t.readBuffer.Reset(frame[10+headerSize:])
+	return nil
+}
+
+// readHeaderInfo reads the key-value headers from the header section of a frame.
+func readHeaderInfo(header *bytes.Reader) (map[string]string, error) {
+	protocolID, err := binary.ReadUvarint(header)
+	if err != nil {
+		return nil, err
+	}
+	if protocolID != headerProtocolID {
+		return nil, fmt.Errorf("unsupported THeader protocol %d", protocolID)
+	}
+	transforms, err := binary.ReadUvarint(header)
+	if err != nil {
+		return nil, err
+	}
+	if transforms != 0 {
+		return nil, errors.New("THeader transforms are not supported")
+	}
+
+	headers := map[string]string{}
+	for header.Len() > 0 {
+		infoType, err := binary.ReadUvarint(header)
+		if err != nil {
+			return nil, err
+		}
+		if infoType != headerInfoKeyValue {
+			// padding, or info the key-value headers can't be told apart from
+			break
+		}
+		count, err := binary.ReadUvarint(header)
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < count; i++ {
+			key, err := readHeaderString(header)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readHeaderString(header)
+			if err != nil {
+				return nil, err
+			}
+			headers[key] = value
+		}
+	}
+	return headers, nil
+}
+
+func writeUvarint(buf *bytes.Buffer, value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	buf.Write(encoded[:binary.PutUvarint(encoded[:], value)])
+}
+
+func writeHeaderString(buf *bytes.Buffer, value string) {
+	writeUvarint(buf, uint64(len(value)))
+	buf.WriteString(value)
+}
+
+func readHeaderString(header *bytes.Reader) (string, error) {
+	size, err := binary.ReadUvarint(header)
+	if err != nil {
+		return "", err
+	}
+	if size > uint64(header.Len()) {
+		return "", errors.New("THeader string size out of range")
+	}
+	value := make([]byte, size)
+	header.Read(value)
+	return string(value), nil
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"bytes"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+func TestTHeaderTransport(t *testing.T) {
+	buffer := thrift.NewTMemoryBuffer()
+	writer := NewTHeaderTransport(buffer)
+	writer.SetWriteHeader("k", "v")
+	writer.seqID = 1
+	writer.Write([]byte("ab"))
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0, 0, 0, 20, // frame size
+		0x0f, 0xff, 0, 0, // magic, flags
+		0, 0, 0, 1, // sequence id
+		0, 2, // header size in words
+		0, 0, // binary protocol, no transforms
+		1, 1, 1, 'k', 1, 'v', // one key-value header
+		'a', 'b',
+	}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatalf("expected frame %v, received %v", expected, buffer.Bytes())
+	}
+
+	reader := NewTHeaderTransport(buffer)
+	payload := make([]byte, 2)
+	if _, err := reader.Read(payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != "ab" || reader.ReadHeaders()["k"] != "v" {
+		t.Errorf("expected payload ab with header k=v, received %q with %v", payload, reader.ReadHeaders())
+	}
+}
+
+func TestTHeaderTransport_InvalidFrame(t *testing.T) {
+	buffer := thrift.NewTMemoryBuffer()
+	buffer.Write([]byte{0, 0, 0, 12, 0x80, 0x01, 0, 1, 0, 0, 0, 4, 't', 'e', 's', 't'})
+	if _, err := NewTHeaderTransport(buffer).Read(make([]byte, 1)); err == nil {
+		t.Error("expected a frame without the THeader magic to fail")
+	}
+}
@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+)
+
+const (
+	latencySamples    = 128 // latencies kept per method for PercentileHedgingOption
+	minLatencySamples = 16  // latencies needed before the percentile is used
+)
+
+// AvoidingTransportFactory is implemented by TransportFactories that can connect to more than one
+// endpoint. Hedged attempts use it to avoid the endpoints already serving the call.
+type AvoidingTransportFactory interface {
+	TransportFactory
+	// GetTransportAvoiding is like GetTransport, but prefers endpoints other than those listed, as
+	// reported by the Addr of their transports.
+	GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error)
+}
+
+// HedgingOption enables hedging of idempotent methods: when an attempt has not completed after
+// delay, another attempt is sent, to a different endpoint if the TransportFactory implements
+// AvoidingTransportFactory, and the first success is returned. Hedged attempts count towards the
+// Retrier's maximum attempts, so the Retrier must allow more than one attempt for hedging to occur,
+// and calls fail with retry.ErrNoAttempts if it allows none.
+//
+// Attempts that lose have their context canceled and their results discarded, but generated
+// clients don't observe the context, so they are left to finish in the background, holding their
+// transports until they do.
+func HedgingOption(delay time.Duration) ClientOption {
+	return func(client *Client) {
+		client.hedger = &hedger{delay: delay}
+	}
+}
+
+// PercentileHedgingOption is like HedgingOption, but hedges once an attempt has run longer than the
+// given percentile, between 0 and 100, of the latencies recently observed for the method. Until
+// enough latencies have been observed, fallback is used as the delay.
+func PercentileHedgingOption(percentile float64, fallback time.Duration) ClientOption {
+	return func(client *Client) {
+		client.hedger = &hedger{
+			delay:      fallback,
+			percentile: percentile,
+			latencies:  map[*Method]*latencyRing{},
+		}
+	}
+}
+
+// hedger holds the hedging configuration of a Client and the latencies it observed.
+type hedger struct {
+	delay      time.Duration
+	percentile float64
+
+	mu        sync.Mutex
+	latencies map[*Method]*latencyRing
+}
+
+// retry returns an Invoker making hedged attempts with attempt.
+func (h *hedger) retry(retrier *retry.Retrier, attempt Invoker) Invoker {
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		results := make([]interface{}, retrier.MaxAttempts())
+		endpoints := &endpointSet{}
+		winner, err := retrier.DoHedged(ctx, h.delayFor(call.Method),
+			func(ctx context.Context, i uint64, wait time.Duration) (err error) {
+				start := time.Now()
+				results[i], err = attempt(ctx, &Call{
+					Method:    call.Method,
+					Args:      call.Args,
+					Attempt:   i,
+					Wait:      wait,
+					Headers:   map[string]string{},
+					endpoints: endpoints,
+				})
+				if err == nil {
+					h.observe(call.Method, time.Since(start))
+				}
+				return err
+			})
+		if err != nil {
+			// the attempt may not have completed if ctx is done
+			return nil, err
+		}
+		return results[winner], nil
+	}
+}
+
+// delayFor returns the hedging delay of method.
+func (h *hedger) delayFor(method *Method) time.Duration {
+	if h.latencies == nil {
+		return h.delay
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring := h.latencies[method]
+	if ring == nil || len(ring.samples) < minLatencySamples {
+		return h.delay
+	}
+	return ring.percentile(h.percentile)
+}
+
+// observe records the latency of a successful attempt.
+func (h *hedger) observe(method *Method, latency time.Duration) {
+	if h.latencies == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring := h.latencies[method]
+	if ring == nil {
+		ring = &latencyRing{}
+		h.latencies[method] = ring
+	}
+	ring.add(latency)
+}
+
+// latencyRing keeps the most recent latencies of a method.
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func (r *latencyRing) add(latency time.Duration) {
+	if len(r.samples) < latencySamples {
+		r.samples = append(r.samples, latency)
+		return
+	}
+	r.samples[r.next] = latency
+	r.next = (r.next + 1) % latencySamples
+}
+
+func (r *latencyRing) percentile(percentile float64) time.Duration {
+	sorted := append([]time.Duration{}, r.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// endpointSet collects the endpoints used by the attempts of a call.
+type endpointSet struct {
+	mu        sync.Mutex
+	endpoints []string
+}
+
+func (s *endpointSet) add(endpoint string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints = append(s.endpoints, endpoint)
+}
+
+func (s *endpointSet) list() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.endpoints...)
+}
@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+)
+
+// addressedTransport is a memory buffer connected to a fake endpoint.
+type addressedTransport struct {
+	*thrift.TMemoryBuffer
+	addr net.Addr
+}
+
+func (t *addressedTransport) Addr() net.Addr {
+	return t.addr
+}
+
+// avoidingTransportFactory hands out transports to numbered endpoints, recording which endpoints
+// hedged attempts asked to avoid.
+type avoidingTransportFactory struct {
+	mu      sync.Mutex
+	next    int
+	avoided [][]string
+}
+
+func (f *avoidingTransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(f.next)), Port: 9090}
+	return &addressedTransport{TMemoryBuffer: thrift.NewTMemoryBuffer(), addr: addr},
+		thrift.NewTBinaryProtocolFactoryDefault(), nil
+}
+
+func (f *avoidingTransportFactory) GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	f.mu.Lock()
+	f.avoided = append(f.avoided, endpoints)
+	f.mu.Unlock()
+	return f.GetTransport()
+}
+
+func TestClient_Hedging(t *testing.T) {
+	factory := &avoidingTransportFactory{}
+	client := NewClient(
+		factory,
+		RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(2), retry.BackoffOption(retry.NoopBackoff))),
+		HedgingOption(10*time.Millisecond),
+	)
+
+	release := make(chan bool)
+	defer close(release)
+	var mu sync.Mutex
+	attempts := 0
+	slowFirst := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		if first {
+			<-release
+			return "slow", nil
+		}
+		return "fast", nil
+	}
+
+	method := &Method{Service: "TestService", Name: "get", Idempotent: true}
+	result, err := client.Invoke(context.Background(), method, nil, slowFirst)
+	if err != nil || result != "fast" {
+		t.Errorf("expected (fast, nil), received (%v, %v)", result, err)
+	}
+
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if len(factory.avoided) != 1 || len(factory.avoided[0]) != 1 || factory.avoided[0][0] != "10.0.0.1:9090" {
+		t.Errorf("expected the hedge to avoid the first endpoint, received %v", factory.avoided)
+	}
+}
+
+func TestClient_HedgingNotIdempotent(t *testing.T) {
+	client := NewClient(
+		&avoidingTransportFactory{},
+		RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(2), retry.BackoffOption(retry.NoopBackoff))),
+		HedgingOption(time.Millisecond),
+	)
+
+	attempts := 0
+	result, err := client.Invoke(context.Background(), testMethod, nil,
+		func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+			attempts++
+			time.Sleep(20 * time.Millisecond)
+			return "slow", nil
+		})
+	if err != nil || result != "slow" || attempts != 1 {
+		t.Errorf("expected a single attempt, received (%v, %v) after %d attempts", result, err, attempts)
+	}
+}
+
+func TestClient_HedgingNoAttempts(t *testing.T) {
+	client := NewClient(
+		&avoidingTransportFactory{},
+		RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(0))),
+		HedgingOption(time.Millisecond),
+	)
+
+	method := &Method{Service: "TestService", Name: "get", Idempotent: true}
+	result, err := client.Invoke(context.Background(), method, nil,
+		func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+			return "ok", nil
+		})
+	if err != retry.ErrNoAttempts || result != nil {
+		t.Errorf("expected (nil, retry.ErrNoAttempts), received (%v, %v)", result, err)
+	}
+}
+
+func TestLatencyRing(t *testing.T) {
+	ring := &latencyRing{}
+	for i := 1; i <= latencySamples+10; i++ {
+		ring.add(time.Duration(i))
+	}
+	if len(ring.samples) != latencySamples {
+		t.Fatalf("expected %d samples, received %d", latencySamples, len(ring.samples))
+	}
+	if p := ring.percentile(100); p != latencySamples+10 {
+		t.Errorf("expected p100 to be the latest maximum, received %d", p)
+	}
+	if p := ring.percentile(0); p != 11 {
+		t.Errorf("expected p0 to be the oldest retained sample, received %d", p)
+	}
+}
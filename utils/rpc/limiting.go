@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// Reasons a call is rejected, see RejectedError.
+const (
+	RejectedRateLimit   = "rate limit exceeded"
+	RejectedMaxInFlight = "too many calls in flight"
+)
+
+// RejectedError is returned for calls rejected by a rate or concurrency limit, without being sent.
+type RejectedError struct {
+	Method *Method
+	Reason string // One of the Rejected constants.
+	Err    error  // The ctx error, when a blocking limit gave up waiting.
+}
+
+func (e *RejectedError) Error() string {
+	message := fmt.Sprintf("%s.%s rejected: %s", e.Method.Service, e.Method.Name, e.Reason)
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+// Unwrap returns the ctx error, if any.
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// limiter admits calls, returning a func to call with the call's error once it is done.
+type limiter interface {
+	acquire(ctx context.Context) (release func(err error), reason string, err error)
+}
+
+// LimitOption configures a rate or concurrency limit.
+type LimitOption func(*limitConfig)
+
+type limitConfig struct {
+	failFast bool
+}
+
+// FailFastOption rejects calls over the limit right away. By default calls wait until they are
+// under the limit or their ctx is done.
+func FailFastOption() LimitOption {
+	return func(config *limitConfig) {
+		config.failFast = true
+	}
+}
+
+// RateLimitOption limits calls to the client to limit per second, allowing bursts of up to burst
+// calls. Retries and hedges of a call are not counted separately.
+func RateLimitOption(limit rate.Limit, burst int, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.limiters = append(client.limiters, newRateLimiter(limit, burst, options))
+	}
+}
+
+// MethodRateLimitOption limits calls to method like RateLimitOption, in addition to any limit on
+// the client.
+func MethodRateLimitOption(method *Method, limit rate.Limit, burst int, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.addMethodLimiter(method, newRateLimiter(limit, burst, options))
+	}
+}
+
+// MaxInFlightOption limits the client to max calls in flight at once.
+func MaxInFlightOption(max int, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.limiters = append(client.limiters, newInFlightLimiter(max, options))
+	}
+}
+
+// MethodMaxInFlightOption limits calls to method like MaxInFlightOption, in addition to any limit
+// on the client.
+func MethodMaxInFlightOption(method *Method, max int, options ...LimitOption) ClientOption {
+	return func(client *Client) {
+		client.addMethodLimiter(method, newInFlightLimiter(max, options))
+	}
+}
+
+func (c *Client) addMethodLimiter(method *Method, l limiter) {
+	if c.methodLimiters == nil {
+		c.methodLimiters = map[*Method][]limiter{}
+	}
+	c.methodLimiters[method] = append(c.methodLimiters[method], l)
+}
+
+// limit returns an Invoker calling invoker once admitted by limiters, in order.
+func limit(limiters []limiter, invoker Invoker) Invoker {
+	return func(ctx context.Context, call *Call) (result interface{}, err error) {
+		for _, l := range limiters {
+			release, reason, acquireErr := l.acquire(ctx)
+			if reason != "" {
+				return nil, &RejectedError{Method: call.Method, Reason: reason, Err: acquireErr}
+			}
+			defer func() { release(err) }()
+		}
+		return invoker(ctx, call)
+	}
+}
+
+// rateLimiter is a token bucket limiter.
+type rateLimiter struct {
+	limiter  *rate.Limiter
+	failFast bool
+}
+
+func newRateLimiter(limit rate.Limit, burst int, options []LimitOption) *rateLimiter {
+	config := newLimitConfig(options)
+	return &rateLimiter{limiter: rate.NewLimiter(limit, burst), failFast: config.failFast}
+}
+
+func (l *rateLimiter) acquire(ctx context.Context) (func(error), string, error) {
+	if l.failFast {
+		if !l.limiter.Allow() {
+			return nil, RejectedRateLimit, nil
+		}
+	} else if err := l.limiter.Wait(ctx); err != nil {
+		// Wait also fails early when ctx's deadline would pass before a token is available.
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			err = context.DeadlineExceeded
+		}
+		return nil, RejectedRateLimit, err
+	}
+	return func(error) {}, "", nil
+}
+
+// inFlightLimiter is a semaphore limiting concurrent calls.
+type inFlightLimiter struct {
+	slots    chan struct{}
+	failFast bool
+}
+
+func newInFlightLimiter(max int, options []LimitOption) *inFlightLimiter {
+	config := newLimitConfig(options)
+	return &inFlightLimiter{slots: make(chan struct{}, max), failFast: config.failFast}
+}
+
+func (l *inFlightLimiter) acquire(ctx context.Context) (func(error), string, error) {
+	release := func(error) { <-l.slots }
+	if l.failFast {
+		select {
+		case l.slots <- struct{}{}:
+			return release, "", nil
+		default:
+			return nil, RejectedMaxInFlight, nil
+		}
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return release, "", nil
+	case <-ctx.Done():
+		return nil, RejectedMaxInFlight, ctx.Err()
+	}
+}
+
+func newLimitConfig(options []LimitOption) *limitConfig {
+	config := &limitConfig{}
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
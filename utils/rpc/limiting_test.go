@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	client := NewClient(&memoryTransportFactory{}, RateLimitOption(rate.Every(time.Hour), 2, FailFastOption()))
+	ok := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return "ok", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Invoke(context.Background(), testMethod, nil, ok); err != nil {
+			t.Fatalf("expected calls within the burst to succeed, received %v", err)
+		}
+	}
+	_, err := client.Invoke(context.Background(), testMethod, nil, ok)
+	rejected, isRejected := err.(*RejectedError)
+	if !isRejected || rejected.Reason != RejectedRateLimit || rejected.Method != testMethod {
+		t.Errorf("expected a rate limit RejectedError, received %v", err)
+	}
+	if ErrorClass(err) != ErrorClassRejected {
+		t.Errorf("expected error class %q, received %q", ErrorClassRejected, ErrorClass(err))
+	}
+
+	blocking := NewClient(&memoryTransportFactory{}, RateLimitOption(rate.Every(time.Hour), 1))
+	blocking.Invoke(context.Background(), testMethod, nil, ok)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = blocking.Invoke(ctx, testMethod, nil, ok)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected blocked calls to give up at their deadline, received %v", err)
+	}
+}
+
+func TestClient_MaxInFlight(t *testing.T) {
+	other := &Method{Service: "TestService", Name: "other"}
+	client := NewClient(&avoidingTransportFactory{}, MethodMaxInFlightOption(testMethod, 1, FailFastOption()))
+
+	started := make(chan bool)
+	release := make(chan bool)
+	blocked := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		started <- true
+		<-release
+		return "ok", nil
+	}
+	ok := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return "ok", nil
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Invoke(context.Background(), testMethod, nil, blocked)
+		done <- err
+	}()
+	<-started
+
+	_, err := client.Invoke(context.Background(), testMethod, nil, ok)
+	if rejected, isRejected := err.(*RejectedError); !isRejected || rejected.Reason != RejectedMaxInFlight {
+		t.Errorf("expected a max in flight RejectedError, received %v", err)
+	}
+	if _, err := client.Invoke(context.Background(), other, nil, ok); err != nil {
+		t.Errorf("expected other methods not to be limited, received %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Invoke(context.Background(), testMethod, nil, ok); err != nil {
+		t.Errorf("expected the slot to be released after the call, received %v", err)
+	}
+}
+
+func TestClient_MaxInFlightBlocking(t *testing.T) {
+	client := NewClient(&avoidingTransportFactory{}, MaxInFlightOption(1))
+
+	started := make(chan bool)
+	release := make(chan bool)
+	go client.Invoke(context.Background(), testMethod, nil, func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		started <- true
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Invoke(ctx, testMethod, nil, func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected blocked calls to give up at their deadline, received %v", err)
+	}
+
+	close(release)
+	if _, err := client.Invoke(context.Background(), testMethod, nil, func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return nil, nil
+	}); err != nil {
+		t.Errorf("expected calls to wait for a slot, received %v", err)
+	}
+}
+
+func TestClient_MaxInFlightSaturatedMethod(t *testing.T) {
+	other := &Method{Service: "TestService", Name: "other"}
+	client := NewClient(&avoidingTransportFactory{}, MaxInFlightOption(2), MethodMaxInFlightOption(testMethod, 1))
+
+	started := make(chan bool, 2)
+	release := make(chan bool)
+	blocked := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		started <- true
+		<-release
+		return nil, nil
+	}
+	ok := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return nil, nil
+	}
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Invoke(context.Background(), testMethod, nil, blocked)
+			done <- err
+		}()
+	}
+	<-started
+	time.Sleep(10 * time.Millisecond) // let the second call wait on the method's slot
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.Invoke(ctx, other, nil, ok); err != nil {
+		t.Errorf("expected calls waiting on a saturated method not to hold client slots, received %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
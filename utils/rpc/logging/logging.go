@@ -0,0 +1,113 @@
+// Package logging emits structured log/slog events for calls made through rpc.Clients.
+//
+// Each attempt made by the Retrier is logged with the service, method, attempt number, backoff
+// wait, endpoint, duration and error class, and each logical call is logged once it completes along
+// with its arguments. Arguments and fields annotated with (sensitive="true") in the thrift file are
+// replaced with a placeholder.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+// Redacted replaces the value of sensitive arguments and fields.
+const Redacted = "[REDACTED]"
+
+type logger struct {
+	logger       *slog.Logger
+	successLevel slog.Level
+	failureLevel slog.Level
+	logArgs      bool
+}
+
+// Option is an optional argument to ClientOption.
+type Option func(l *logger)
+
+// SuccessLevelOption sets the level successful calls and attempts are logged at.
+// Defaults to slog.LevelDebug.
+func SuccessLevelOption(level slog.Level) Option {
+	return func(l *logger) {
+		l.successLevel = level
+	}
+}
+
+// FailureLevelOption sets the level failed calls and attempts are logged at.
+// Defaults to slog.LevelWarn.
+func FailureLevelOption(level slog.Level) Option {
+	return func(l *logger) {
+		l.failureLevel = level
+	}
+}
+
+// ArgsOption sets whether call events include the call's arguments.
+// Defaults to true.
+func ArgsOption(logArgs bool) Option {
+	return func(l *logger) {
+		l.logArgs = logArgs
+	}
+}
+
+// ClientOption configures an rpc.Client to log its calls and attempts to slogger.
+func ClientOption(slogger *slog.Logger, options ...Option) rpc.ClientOption {
+	l := &logger{
+		logger:       slogger,
+		successLevel: slog.LevelDebug,
+		failureLevel: slog.LevelWarn,
+		logArgs:      true,
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return func(client *rpc.Client) {
+		rpc.InterceptorOption(l.interceptCall)(client)
+		rpc.AttemptInterceptorOption(l.interceptAttempt)(client)
+	}
+}
+
+func (l *logger) interceptCall(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	start := time.Now()
+	result, err := next(ctx, call)
+
+	attrs := append(methodAttrs(call), slog.Duration("duration", time.Since(start)))
+	if l.logArgs && call.Args != nil {
+		attrs = append(attrs, slog.Any("args", Redact(call.Args, call.Method.Sensitive)))
+	}
+	l.log(ctx, "thrift call", attrs, err)
+	return result, err
+}
+
+func (l *logger) interceptAttempt(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	start := time.Now()
+	result, err := next(ctx, call)
+
+	attrs := append(methodAttrs(call),
+		slog.Uint64("attempt", call.Attempt),
+		slog.Duration("backoff", call.Wait),
+		slog.String("endpoint", call.Endpoint),
+		slog.Duration("duration", time.Since(start)),
+	)
+	l.log(ctx, "thrift attempt", attrs, err)
+	return result, err
+}
+
+func (l *logger) log(ctx context.Context, msg string, attrs []slog.Attr, err error) {
+	level := l.successLevel
+	if err != nil {
+		level = l.failureLevel
+		attrs = append(attrs, slog.String("error", err.Error()), slog.String("error_class", rpc.ErrorClass(err)))
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func methodAttrs(call *rpc.Call) []slog.Attr {
+	return []slog.Attr{
+		slog.String("service", call.Method.Service),
+		slog.String("method", call.Method.Name),
+	}
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+type credentials struct {
+	User     string `thrift:"user,1" json:"user"`
+	Password string `thrift:"password,2" json:"password"`
+}
+
+type loginArgs struct {
+	Credentials *credentials   `thrift:"credentials,1" json:"credentials"`
+	History     []*credentials `thrift:"history,2" json:"history"`
+	Token       string         `thrift:"token,3" json:"token"`
+}
+
+func (p *loginArgs) Read(thrift.TProtocol) error  { return nil }
+func (p *loginArgs) Write(thrift.TProtocol) error { return nil }
+
+type memoryTransportFactory struct{}
+
+func (memoryTransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	return thrift.NewTMemoryBuffer(), thrift.NewTBinaryProtocolFactoryDefault(), nil
+}
+
+func TestRedact(t *testing.T) {
+	args := &loginArgs{
+		Credentials: &credentials{User: "alice", Password: "secret"},
+		History:     []*credentials{{User: "bob", Password: "hunter2"}},
+		Token:       "abc",
+	}
+
+	actual := Redact(args, []string{"credentials.password", "history.password", "token"})
+	expected := map[string]interface{}{
+		"credentials": map[string]interface{}{"user": "alice", "password": Redacted},
+		"history":     []interface{}{map[string]interface{}{"user": "bob", "password": Redacted}},
+		"token":       Redacted,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Redact => %v, want %v", actual, expected)
+	}
+}
+
+func TestClientOption(t *testing.T) {
+	var buf bytes.Buffer
+	client := rpc.NewClient(
+		memoryTransportFactory{},
+		rpc.RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(2), retry.BackoffOption(retry.NoopBackoff))),
+		ClientOption(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	method := &rpc.Method{Service: "AuthService", Name: "login", Sensitive: []string{"token"}}
+	args := &loginArgs{Token: "abc"}
+	attempts := 0
+	client.Invoke(context.Background(), method, args,
+		func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("err")
+			}
+			return nil, nil
+		})
+
+	var events []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		event := map[string]interface{}{}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("unexpected error decoding log: %v", err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 2 attempt events and 1 call event, received %d", len(events))
+	}
+
+	failed, succeeded, call := events[0], events[1], events[2]
+	if failed["msg"] != "thrift attempt" || failed["level"] != "WARN" || failed["error_class"] != rpc.ErrorClassUnknown {
+		t.Errorf("unexpected failed attempt event %v", failed)
+	}
+	if succeeded["attempt"] != 1.0 || succeeded["level"] != "DEBUG" {
+		t.Errorf("unexpected successful attempt event %v", succeeded)
+	}
+	if call["msg"] != "thrift call" || call["service"] != "AuthService" || call["method"] != "login" {
+		t.Errorf("unexpected call event %v", call)
+	}
+	if logged := call["args"].(map[string]interface{}); logged["token"] != Redacted {
+		t.Errorf("expected token to be redacted, received %v", logged)
+	}
+}
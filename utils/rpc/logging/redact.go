@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+// Redact converts a thrift gen struct into maps keyed by thrift field names, slices and scalars,
+// replacing the values at the sensitive paths with Redacted. Paths are dot-separated thrift field
+// names, as in rpc.Method.Sensitive; elements of lists, sets and maps share the path of their
+// container.
+func Redact(value interface{}, sensitive []string) interface{} {
+	paths := make(map[string]bool, len(sensitive))
+	for _, path := range sensitive {
+		paths[path] = true
+	}
+	return redact(reflect.ValueOf(value), "", paths)
+}
+
+func redact(value reflect.Value, path string, sensitive map[string]bool) interface{} {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return redact(value.Elem(), path, sensitive)
+	case reflect.Struct:
+		fields := map[string]interface{}{}
+		structType := value.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			name := rpc.ThriftFieldName(structType.Field(i))
+			if name == "" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			if sensitive[fieldPath] {
+				fields[name] = Redacted
+			} else {
+				fields[name] = redact(value.Field(i), fieldPath, sensitive)
+			}
+		}
+		return fields
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Interface() // binary
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = redact(value.Index(i), path, sensitive)
+		}
+		return items
+	case reflect.Map:
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(redact(iter.Key(), path, sensitive))
+			entries[key] = redact(iter.Value(), path, sensitive)
+		}
+		return entries
+	default:
+		return value.Interface()
+	}
+}
@@ -0,0 +1,134 @@
+// Package metrics records Prometheus metrics for calls made through rpc.Clients.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultNamespace = "thrift_client"
+
+var (
+	callLabels  = []string{"service", "method"}
+	errorLabels = []string{"service", "method", "class"}
+)
+
+// Metrics holds the Prometheus collectors updated by clients configured with ClientOption:
+//
+//	<namespace>_requests_total               logical calls, by service and method
+//	<namespace>_attempts_total               attempts made by the Retrier, by service and method
+//	<namespace>_request_duration_seconds     latency of logical calls, including retries
+//	<namespace>_errors_total                 failed logical calls, by service, method and rpc.ErrorClass
+//	<namespace>_retry_backoff_seconds        time spent backing off before each retry
+//
+// A single Metrics can be shared by any number of clients.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	requests *prometheus.CounterVec
+	attempts *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	backoff  *prometheus.HistogramVec
+}
+
+// Option is an optional argument to NewMetrics.
+type Option func(m *Metrics)
+
+// NamespaceOption sets the prefix of every metric name.
+// Defaults to "thrift_client".
+func NamespaceOption(namespace string) Option {
+	return func(m *Metrics) {
+		m.namespace = namespace
+	}
+}
+
+// BucketsOption sets the histogram buckets, in seconds, used for latencies and backoffs.
+// Defaults to prometheus.DefBuckets.
+func BucketsOption(buckets []float64) Option {
+	return func(m *Metrics) {
+		m.buckets = buckets
+	}
+}
+
+// NewMetrics creates the collectors and registers them with registerer.
+func NewMetrics(registerer prometheus.Registerer, options ...Option) (*Metrics, error) {
+	m := &Metrics{
+		namespace: defaultNamespace,
+		buckets:   prometheus.DefBuckets,
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Name:      "requests_total",
+		Help:      "Logical calls made through thrift clients.",
+	}, callLabels)
+	m.attempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Name:      "attempts_total",
+		Help:      "Attempts made by thrift clients, including retries.",
+	}, callLabels)
+	m.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of logical calls made through thrift clients, including retries.",
+		Buckets:   m.buckets,
+	}, callLabels)
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Name:      "errors_total",
+		Help:      "Logical calls made through thrift clients that failed, by error class.",
+	}, errorLabels)
+	m.backoff = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Name:      "retry_backoff_seconds",
+		Help:      "Time thrift clients spent backing off before retrying.",
+		Buckets:   m.buckets,
+	}, callLabels)
+
+	for _, collector := range []prometheus.Collector{m.requests, m.attempts, m.latency, m.errors, m.backoff} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ClientOption configures an rpc.Client to record its calls in m.
+func ClientOption(m *Metrics) rpc.ClientOption {
+	return func(client *rpc.Client) {
+		rpc.InterceptorOption(m.interceptCall)(client)
+		rpc.AttemptInterceptorOption(m.interceptAttempt)(client)
+	}
+}
+
+func (m *Metrics) interceptCall(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	service, method := call.Method.Service, call.Method.Name
+	m.requests.WithLabelValues(service, method).Inc()
+
+	start := time.Now()
+	result, err := next(ctx, call)
+	m.latency.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(service, method, rpc.ErrorClass(err)).Inc()
+	}
+	return result, err
+}
+
+func (m *Metrics) interceptAttempt(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	service, method := call.Method.Service, call.Method.Name
+	m.attempts.WithLabelValues(service, method).Inc()
+	if call.Attempt > 0 {
+		m.backoff.WithLabelValues(service, method).Observe(call.Wait.Seconds())
+	}
+	return next(ctx, call)
+}
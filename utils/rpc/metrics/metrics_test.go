@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type memoryTransportFactory struct{}
+
+func (memoryTransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	return thrift.NewTMemoryBuffer(), thrift.NewTBinaryProtocolFactoryDefault(), nil
+}
+
+var testMethod = &rpc.Method{Service: "TestService", Name: "test"}
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	m, err := NewMetrics(registry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := rpc.NewClient(
+		memoryTransportFactory{},
+		rpc.RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(3), retry.BackoffOption(retry.NoopBackoff))),
+		ClientOption(m),
+	)
+	fail := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return nil, thrift.NewTTransportException(thrift.TIMED_OUT, "timeout")
+	}
+	succeed := func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+		return nil, nil
+	}
+	client.Invoke(context.Background(), testMethod, nil, fail)
+	client.Invoke(context.Background(), testMethod, nil, succeed)
+
+	expected := `
+# HELP thrift_client_attempts_total Attempts made by thrift clients, including retries.
+# TYPE thrift_client_attempts_total counter
+thrift_client_attempts_total{method="test",service="TestService"} 4
+# HELP thrift_client_errors_total Logical calls made through thrift clients that failed, by error class.
+# TYPE thrift_client_errors_total counter
+thrift_client_errors_total{class="transport",method="test",service="TestService"} 1
+# HELP thrift_client_requests_total Logical calls made through thrift clients.
+# TYPE thrift_client_requests_total counter
+thrift_client_requests_total{method="test",service="TestService"} 2
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"thrift_client_attempts_total", "thrift_client_errors_total", "thrift_client_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if count := testutil.CollectAndCount(m.backoff); count != 1 {
+		t.Errorf("expected 1 backoff series, received %d", count)
+	}
+	if count := testutil.CollectAndCount(m.latency); count != 1 {
+		t.Errorf("expected 1 latency series, received %d", count)
+	}
+}
+
+func TestNewMetrics_AlreadyRegistered(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	if _, err := NewMetrics(registry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := NewMetrics(registry)
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if !errors.As(err, &alreadyRegistered) {
+		t.Errorf("expected AlreadyRegisteredError, received %v", err)
+	}
+}
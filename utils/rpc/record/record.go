@@ -0,0 +1,168 @@
+// Package record captures thrift calls made through an rpc.TransportFactory and replays them
+// offline, so integration tests can run against real traffic without the real backend.
+//
+// Calls are captured at the transport level, as the serialized arguments and response of each call,
+// rather than by an rpc.Interceptor, which only sees the decoded result, not the result struct to
+// replay. Recordings are thus tied to the protocol they were made with. Protocols that buffer reads, such as
+// the JSON protocols, are not supported.
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+// Entry is a single recorded call.
+type Entry struct {
+	Method       string              `json:"method"`
+	Args         []byte              `json:"args"`          // the serialized argument struct
+	ResponseType thrift.TMessageType `json:"response_type"` // REPLY or EXCEPTION; zero for oneway calls
+	Response     []byte              `json:"response"`      // the serialized result struct or exception
+}
+
+// ReadEntries reads entries written by a Recorder.
+func ReadEntries(r io.Reader) ([]*Entry, error) {
+	entries := []*Entry{}
+	decoder := json.NewDecoder(r)
+	for {
+		entry := &Entry{}
+		err := decoder.Decode(entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// Recorder wraps an rpc.TransportFactory and writes every call made over its transports to an
+// io.Writer, one JSON encoded Entry per line. Calls whose response could not be read, such as those
+// that failed with a transport error, are not recorded.
+type Recorder struct {
+	factory rpc.TransportFactory
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder returns a Recorder writing the calls made through factory to w.
+func NewRecorder(factory rpc.TransportFactory, w io.Writer) *Recorder {
+	return &Recorder{factory: factory, encoder: json.NewEncoder(w)}
+}
+
+// GetTransport returns a transport from the wrapped factory that records its calls.
+func (r *Recorder) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	return r.wrap(r.factory.GetTransport())
+}
+
+// GetTransportAvoiding is like GetTransport, but passes endpoints on to the wrapped factory if it
+// is an rpc.AvoidingTransportFactory, so hedged calls still avoid them.
+func (r *Recorder) GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	if factory, ok := r.factory.(rpc.AvoidingTransportFactory); ok {
+		return r.wrap(factory.GetTransportAvoiding(endpoints))
+	}
+	return r.GetTransport()
+}
+
+func (r *Recorder) wrap(trans thrift.TTransport, protocolFactory thrift.TProtocolFactory, err error) (
+	thrift.TTransport, thrift.TProtocolFactory, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return &recordingTransport{TTransport: trans, recorder: r, protocolFactory: protocolFactory}, protocolFactory, nil
+}
+
+// Err returns the first error encountered while writing entries, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(entry *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.encoder.Encode(entry)
+	}
+}
+
+// recordingTransport tees the bytes of each call into buffers and records the call once it is over,
+// that is when the next request starts or the transport is closed.
+type recordingTransport struct {
+	thrift.TTransport
+	recorder        *Recorder
+	protocolFactory thrift.TProtocolFactory
+
+	request  bytes.Buffer
+	response bytes.Buffer
+}
+
+func (t *recordingTransport) Write(buf []byte) (int, error) {
+	if t.response.Len() > 0 {
+		t.finish()
+	}
+	t.request.Write(buf)
+	return t.TTransport.Write(buf)
+}
+
+func (t *recordingTransport) Read(buf []byte) (int, error) {
+	n, err := t.TTransport.Read(buf)
+	t.response.Write(buf[:n])
+	return n, err
+}
+
+func (t *recordingTransport) Close() error {
+	t.finish()
+	return t.TTransport.Close()
+}
+
+// Addr returns the remote address of the wrapped transport, if it exposes one, so calls still
+// report their endpoint.
+func (t *recordingTransport) Addr() net.Addr {
+	if addressable, ok := t.TTransport.(interface{ Addr() net.Addr }); ok {
+		return addressable.Addr()
+	}
+	return nil
+}
+
+// finish records the buffered call, if it is complete, and resets the buffers.
+func (t *recordingTransport) finish() {
+	defer t.request.Reset()
+	defer t.response.Reset()
+
+	name, messageType, _, args, err := splitMessage(t.protocolFactory, t.request.Bytes())
+	if err != nil {
+		return
+	}
+	entry := &Entry{Method: name, Args: args}
+	if messageType != thrift.ONEWAY {
+		_, entry.ResponseType, _, entry.Response, err = splitMessage(t.protocolFactory, t.response.Bytes())
+		if err != nil {
+			return
+		}
+	}
+	t.recorder.record(entry)
+}
+
+// splitMessage decodes the message header at the start of message and returns it along with the
+// serialized body that follows it.
+func splitMessage(protocolFactory thrift.TProtocolFactory, message []byte) (
+	name string, messageType thrift.TMessageType, seqID int32, body []byte, err error) {
+	buffer := thrift.NewTMemoryBuffer()
+	buffer.Write(message)
+	name, messageType, seqID, err = protocolFactory.GetProtocol(buffer).ReadMessageBegin()
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	return name, messageType, seqID, append([]byte{}, buffer.Bytes()...), nil
+}
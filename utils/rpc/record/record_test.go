@@ -0,0 +1,186 @@
+package record
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc/rpctest"
+)
+
+// doubleProcessor replies to every call with its i32 argument doubled, and fails negative arguments
+// with an application exception.
+type doubleProcessor struct {
+	calls int
+}
+
+func (p *doubleProcessor) Process(in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin()
+	if err != nil {
+		return false, err
+	}
+	value, err := readI32Struct(in)
+	if err != nil {
+		return false, err
+	}
+	in.ReadMessageEnd()
+
+	p.calls++
+	if value < 0 {
+		out.WriteMessageBegin(name, thrift.EXCEPTION, seqID)
+		thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "negative").Write(out)
+	} else {
+		out.WriteMessageBegin(name, thrift.REPLY, seqID)
+		writeI32Struct(out, 0, 2*value)
+	}
+	out.WriteMessageEnd()
+	return true, out.Flush()
+}
+
+func writeI32Struct(p thrift.TProtocol, id int16, value int32) {
+	p.WriteStructBegin("struct")
+	p.WriteFieldBegin("value", thrift.I32, id)
+	p.WriteI32(value)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+	p.WriteStructEnd()
+}
+
+func readI32Struct(p thrift.TProtocol) (int32, error) {
+	var value int32
+	p.ReadStructBegin()
+	for {
+		_, typeID, _, err := p.ReadFieldBegin()
+		if err != nil {
+			return 0, err
+		}
+		if typeID == thrift.STOP {
+			break
+		}
+		if value, err = p.ReadI32(); err != nil {
+			return 0, err
+		}
+		p.ReadFieldEnd()
+	}
+	return value, p.ReadStructEnd()
+}
+
+// double performs a single call the way an Apache-generated client would.
+func double(factory rpc.TransportFactory, seqID int32, value int32) (int32, error) {
+	transport, protocolFactory, err := factory.GetTransport()
+	if err != nil {
+		return 0, err
+	}
+	defer transport.Close()
+
+	protocol := protocolFactory.GetProtocol(transport)
+	protocol.WriteMessageBegin("double", thrift.CALL, seqID)
+	writeI32Struct(protocol, 1, value)
+	protocol.WriteMessageEnd()
+	if err = protocol.Flush(); err != nil {
+		return 0, err
+	}
+
+	_, messageType, responseSeqID, err := protocol.ReadMessageBegin()
+	if err != nil {
+		return 0, err
+	}
+	if responseSeqID != seqID {
+		return 0, thrift.NewTApplicationException(thrift.BAD_SEQUENCE_ID, "out of sequence response")
+	}
+	if messageType == thrift.EXCEPTION {
+		exception, err := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "").Read(protocol)
+		if err != nil {
+			return 0, err
+		}
+		return 0, exception
+	}
+	return readI32Struct(protocol)
+}
+
+func TestRecordReplay(t *testing.T) {
+	var recording bytes.Buffer
+	processor := &doubleProcessor{}
+	recorder := NewRecorder(rpctest.NewTransportFactory(processor), &recording)
+	for _, value := range []int32{1, 2, -1} {
+		double(recorder, 1, value)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("unexpected recording error: %v", err)
+	}
+
+	entries, err := ReadEntries(&recording)
+	if err != nil {
+		t.Fatalf("unexpected error reading entries: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, received %d", len(entries))
+	}
+
+	replayer := NewReplayer(entries)
+	for _, value := range []int32{2, 1, 2} {
+		resp, err := double(replayer, 7, value)
+		if err != nil {
+			t.Fatalf("unexpected error replaying %d: %v", value, err)
+		}
+		if resp != 2*value {
+			t.Errorf("expected %d, received %d", 2*value, resp)
+		}
+	}
+	if _, err = double(replayer, 7, -1); err == nil || err.Error() != "negative" {
+		t.Errorf("expected recorded exception, received %v", err)
+	}
+	if _, err = double(replayer, 7, 3); err == nil {
+		t.Error("expected unrecorded call to fail")
+	}
+	if processor.calls != 3 {
+		t.Errorf("expected replay not to reach the server, received %d calls", processor.calls)
+	}
+}
+
+func TestReplayer_Sequence(t *testing.T) {
+	entries := []*Entry{
+		{Method: "double", Args: []byte("a"), ResponseType: thrift.EXCEPTION},
+		{Method: "double", Args: []byte("a"), ResponseType: thrift.REPLY},
+	}
+	replayer := NewReplayer(entries)
+	for _, expected := range []*Entry{entries[0], entries[1], entries[1]} {
+		entry, err := replayer.next("double", []byte("a"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entry != expected {
+			t.Errorf("expected entries to be served in order, then repeat the last")
+		}
+	}
+}
+
+// avoidingFactory is an rpc.AvoidingTransportFactory recording the endpoints it was asked to avoid.
+type avoidingFactory struct {
+	*rpctest.TransportFactory
+	avoided []string
+}
+
+func (f *avoidingFactory) GetTransportAvoiding(endpoints []string) (thrift.TTransport, thrift.TProtocolFactory, error) {
+	f.avoided = endpoints
+	return f.GetTransport()
+}
+
+func TestRecorder_Endpoints(t *testing.T) {
+	factory := &avoidingFactory{TransportFactory: rpctest.NewTransportFactory(&doubleProcessor{})}
+	var recorder rpc.AvoidingTransportFactory = NewRecorder(factory, &bytes.Buffer{})
+	transport, _, err := recorder.GetTransportAvoiding([]string{"10.0.0.1:9090"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer transport.Close()
+
+	if len(factory.avoided) != 1 || factory.avoided[0] != "10.0.0.1:9090" {
+		t.Errorf("expected the endpoint to be avoided by the wrapped factory, received %v", factory.avoided)
+	}
+	if addressable, ok := transport.(interface{ Addr() net.Addr }); !ok || addressable.Addr() == nil {
+		t.Error("expected the transport to expose the address of the wrapped one")
+	}
+}
@@ -0,0 +1,155 @@
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// Replayer implements rpc.TransportFactory by answering calls from recorded entries instead of a
+// server. Calls are matched on method name and serialized arguments. When several entries match a
+// call, they are served in recorded order and the last one is repeated once they run out, so a
+// recorded sequence of failures followed by a success replays the same way.
+type Replayer struct {
+	protocolFactory thrift.TProtocolFactory
+
+	mu      sync.Mutex
+	entries map[string][]*Entry
+	served  map[string]int
+}
+
+// ReplayerOption is an optional argument to NewReplayer.
+type ReplayerOption func(r *Replayer)
+
+// ProtocolFactoryOption sets the protocol the entries were recorded with.
+// Defaults to the binary protocol.
+func ProtocolFactoryOption(protocolFactory thrift.TProtocolFactory) ReplayerOption {
+	return func(r *Replayer) {
+		r.protocolFactory = protocolFactory
+	}
+}
+
+// NewReplayer returns a Replayer serving entries.
+func NewReplayer(entries []*Entry, options ...ReplayerOption) *Replayer {
+	r := &Replayer{
+		protocolFactory: thrift.NewTBinaryProtocolFactoryDefault(),
+		entries:         map[string][]*Entry{},
+		served:          map[string]int{},
+	}
+
+	for _, entry := range entries {
+		key := entryKey(entry.Method, entry.Args)
+		r.entries[key] = append(r.entries[key], entry)
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// GetTransport returns a transport answering calls from the recorded entries.
+func (r *Replayer) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	return &replayTransport{replayer: r}, r.protocolFactory, nil
+}
+
+// next returns the entry to serve for a call.
+func (r *Replayer) next(method string, args []byte) (*Entry, error) {
+	key := entryKey(method, args)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries[key]
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("record: no recorded call to %s with matching args", method)
+	}
+	i := r.served[key]
+	if i < len(entries)-1 {
+		r.served[key] = i + 1
+	}
+	return entries[i], nil
+}
+
+func entryKey(method string, args []byte) string {
+	return method + "\x00" + string(args)
+}
+
+// replayTransport buffers each request and, when it is flushed, queues the matching recorded
+// response for reading.
+type replayTransport struct {
+	replayer *Replayer
+	request  bytes.Buffer
+	response *thrift.TMemoryBuffer
+	closed   bool
+}
+
+func (t *replayTransport) Open() error {
+	return nil
+}
+
+func (t *replayTransport) IsOpen() bool {
+	return !t.closed
+}
+
+func (t *replayTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *replayTransport) Write(buf []byte) (int, error) {
+	if t.closed {
+		return 0, thrift.NewTTransportException(thrift.NOT_OPEN, "record: transport is closed")
+	}
+	return t.request.Write(buf)
+}
+
+func (t *replayTransport) Read(buf []byte) (int, error) {
+	if t.closed {
+		return 0, thrift.NewTTransportException(thrift.NOT_OPEN, "record: transport is closed")
+	}
+	if t.response == nil {
+		return 0, thrift.NewTTransportException(thrift.END_OF_FILE, "record: no response pending")
+	}
+	return t.response.Read(buf)
+}
+
+func (t *replayTransport) RemainingBytes() uint64 {
+	if t.response == nil {
+		return 0
+	}
+	return t.response.RemainingBytes()
+}
+
+// Flush looks up the buffered request and queues its recorded response, rewritten with the
+// request's sequence id.
+func (t *replayTransport) Flush() error {
+	defer t.request.Reset()
+
+	protocolFactory := t.replayer.protocolFactory
+	name, messageType, seqID, args, err := splitMessage(protocolFactory, t.request.Bytes())
+	if err != nil {
+		return err
+	}
+	entry, err := t.replayer.next(name, args)
+	if err != nil {
+		return err
+	}
+	if messageType == thrift.ONEWAY {
+		return nil
+	}
+
+	t.response = thrift.NewTMemoryBuffer()
+	protocol := protocolFactory.GetProtocol(t.response)
+	if err = protocol.WriteMessageBegin(name, entry.ResponseType, seqID); err != nil {
+		return err
+	}
+	if err = protocol.Flush(); err != nil {
+		return err
+	}
+	_, err = t.response.Write(entry.Response)
+	return err
+}
@@ -0,0 +1,66 @@
+// Package rpctest provides an in-memory rpc.TransportFactory for exercising wrapped clients
+// end to end without opening sockets.
+package rpctest
+
+import (
+	"net"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// TransportFactory implements rpc.TransportFactory by connecting every transport it returns to an
+// in-process thrift.TProcessor over an in-memory pipe. Requests are serialized by the client,
+// deserialized and handled by the processor, and the responses (including exceptions) travel
+// back the same way they would over a socket.
+type TransportFactory struct {
+	processor       thrift.TProcessor
+	protocolFactory thrift.TProtocolFactory
+}
+
+// Option is an optional argument to NewTransportFactory.
+type Option func(f *TransportFactory)
+
+// ProtocolFactoryOption sets the protocol used on both ends of the pipe.
+// Defaults to the binary protocol.
+func ProtocolFactoryOption(protocolFactory thrift.TProtocolFactory) Option {
+	return func(f *TransportFactory) {
+		f.protocolFactory = protocolFactory
+	}
+}
+
+// NewTransportFactory returns a TransportFactory serving calls with processor, which is usually the
+// Apache-generated New<Service>Processor wrapping a test handler.
+func NewTransportFactory(processor thrift.TProcessor, options ...Option) *TransportFactory {
+	f := &TransportFactory{
+		processor:       processor,
+		protocolFactory: thrift.NewTBinaryProtocolFactoryDefault(),
+	}
+
+	for _, option := range options {
+		option(f)
+	}
+
+	return f
+}
+
+// GetTransport opens a new pipe, starts serving its far end with the processor and returns the near
+// end. Closing the transport stops the server goroutine.
+func (f *TransportFactory) GetTransport() (thrift.TTransport, thrift.TProtocolFactory, error) {
+	clientConn, serverConn := net.Pipe()
+	go f.serve(serverConn)
+	return thrift.NewTSocketFromConnTimeout(clientConn, 0), f.protocolFactory, nil
+}
+
+// serve processes messages from conn until the client hangs up or the processor fails.
+func (f *TransportFactory) serve(conn net.Conn) {
+	transport := thrift.NewTSocketFromConnTimeout(conn, 0)
+	defer transport.Close()
+
+	protocol := f.protocolFactory.GetProtocol(transport)
+	for {
+		ok, err := f.processor.Process(protocol, protocol)
+		if err != nil || !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package rpctest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+)
+
+// echoProcessor answers "echo" calls carrying a single string field with the same string, like an
+// Apache-generated processor would. It fails the first `failures` calls with an application
+// exception, and answers calls echoing "missing" with the declared notFound exception.
+type echoProcessor struct {
+	failures int
+	calls    int
+}
+
+func (p *echoProcessor) Process(in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin()
+	if err != nil {
+		return false, err
+	}
+	args := &echoArgs{}
+	if err = args.Read(in); err != nil {
+		return false, err
+	}
+	if err = in.ReadMessageEnd(); err != nil {
+		return false, err
+	}
+
+	p.calls++
+	switch {
+	case p.calls <= p.failures:
+		out.WriteMessageBegin(name, thrift.EXCEPTION, seqID)
+		thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "boom").Write(out)
+	case args.Value == "missing":
+		out.WriteMessageBegin(name, thrift.REPLY, seqID)
+		writeEchoResult(out, nil, &notFound{Key: args.Value})
+	default:
+		out.WriteMessageBegin(name, thrift.REPLY, seqID)
+		writeEchoResult(out, &args.Value, nil)
+	}
+	out.WriteMessageEnd()
+	return true, out.Flush()
+}
+
+// echoArgs is the args struct of echo, shaped like an Apache-generated one.
+type echoArgs struct {
+	Value string
+}
+
+func (a *echoArgs) Write(p thrift.TProtocol) error {
+	return writeStringStruct(p, 1, a.Value)
+}
+
+func (a *echoArgs) Read(p thrift.TProtocol) (err error) {
+	a.Value, err = readStringStruct(p)
+	return err
+}
+
+// notFound is an exception declared for echo, shaped like an Apache-generated one.
+type notFound struct {
+	Key string
+}
+
+func (e *notFound) Error() string {
+	return "not found: " + e.Key
+}
+
+func (e *notFound) Write(p thrift.TProtocol) error {
+	return writeStringStruct(p, 1, e.Key)
+}
+
+func (e *notFound) Read(p thrift.TProtocol) (err error) {
+	e.Key, err = readStringStruct(p)
+	return err
+}
+
+// writeEchoResult writes the result struct of echo, holding either its success or its exception.
+func writeEchoResult(p thrift.TProtocol, success *string, exception *notFound) error {
+	p.WriteStructBegin("echo_result")
+	if success != nil {
+		p.WriteFieldBegin("success", thrift.STRING, 0)
+		p.WriteString(*success)
+		p.WriteFieldEnd()
+	}
+	if exception != nil {
+		p.WriteFieldBegin("notFound", thrift.STRUCT, 1)
+		exception.Write(p)
+		p.WriteFieldEnd()
+	}
+	p.WriteFieldStop()
+	return p.WriteStructEnd()
+}
+
+// readEchoResult reads the result struct of echo, returning its exception as an error.
+func readEchoResult(p thrift.TProtocol) (string, error) {
+	var success string
+	var exception *notFound
+	if _, err := p.ReadStructBegin(); err != nil {
+		return "", err
+	}
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+		if err != nil {
+			return "", err
+		}
+		if typeID == thrift.STOP {
+			break
+		}
+		switch id {
+		case 0:
+			success, err = p.ReadString()
+		case 1:
+			exception = &notFound{}
+			err = exception.Read(p)
+		default:
+			err = p.Skip(typeID)
+		}
+		if err != nil {
+			return "", err
+		}
+		p.ReadFieldEnd()
+	}
+	if err := p.ReadStructEnd(); err != nil {
+		return "", err
+	}
+	if exception != nil {
+		return "", exception
+	}
+	return success, nil
+}
+
+func writeStringStruct(p thrift.TProtocol, id int16, value string) error {
+	p.WriteStructBegin("args")
+	p.WriteFieldBegin("value", thrift.STRING, id)
+	p.WriteString(value)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+	return p.WriteStructEnd()
+}
+
+func readStringStruct(p thrift.TProtocol) (string, error) {
+	var value string
+	if _, err := p.ReadStructBegin(); err != nil {
+		return "", err
+	}
+	for {
+		_, typeID, _, err := p.ReadFieldBegin()
+		if err != nil {
+			return "", err
+		}
+		if typeID == thrift.STOP {
+			break
+		}
+		if value, err = p.ReadString(); err != nil {
+			return "", err
+		}
+		p.ReadFieldEnd()
+	}
+	return value, p.ReadStructEnd()
+}
+
+var echoMethod = &rpc.Method{Service: "EchoService", Name: "echo"}
+
+// echo performs a single echo call with the args of the call the way an Apache-generated client
+// would.
+func echo(args *echoArgs) rpc.AttemptFunc {
+	return func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+		protocol := protocolFactory.GetProtocol(transport)
+		protocol.WriteMessageBegin("echo", thrift.CALL, 1)
+		args.Write(protocol)
+		protocol.WriteMessageEnd()
+		if err := protocol.Flush(); err != nil {
+			return nil, err
+		}
+
+		_, messageType, _, err := protocol.ReadMessageBegin()
+		if err != nil {
+			return nil, err
+		}
+		if messageType == thrift.EXCEPTION {
+			exception, err := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "").Read(protocol)
+			if err != nil {
+				return nil, err
+			}
+			return nil, exception
+		}
+		return readEchoResult(protocol)
+	}
+}
+
+// invokeEcho calls echo with value through client.
+func invokeEcho(client *rpc.Client, value string) (interface{}, error) {
+	args := &echoArgs{Value: value}
+	return client.Invoke(context.Background(), echoMethod, args, echo(args))
+}
+
+func TestTransportFactory(t *testing.T) {
+	client := rpc.NewClient(NewTransportFactory(&echoProcessor{}))
+	resp, err := invokeEcho(client, "hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp != "hello" {
+		t.Errorf("expected %q, received %q", "hello", resp)
+	}
+}
+
+func TestTransportFactory_Exception(t *testing.T) {
+	client := rpc.NewClient(NewTransportFactory(&echoProcessor{failures: 1}))
+	_, err := invokeEcho(client, "hello")
+	var exception thrift.TApplicationException
+	if !errors.As(err, &exception) || exception.TypeId() != thrift.INTERNAL_ERROR {
+		t.Errorf("expected internal error application exception, received %v", err)
+	}
+}
+
+func TestTransportFactory_DeclaredException(t *testing.T) {
+	client := rpc.NewClient(NewTransportFactory(&echoProcessor{}))
+	_, err := invokeEcho(client, "missing")
+	exception, ok := err.(*notFound)
+	if !ok || exception.Key != "missing" {
+		t.Errorf("expected a notFound exception for missing, received %v", err)
+	}
+	if class := rpc.ErrorClass(err); class != rpc.ErrorClassException {
+		t.Errorf("expected error class %q, received %q", rpc.ErrorClassException, class)
+	}
+}
+
+func TestTransportFactory_Retries(t *testing.T) {
+	// application exceptions are retried, declared exceptions are not
+	retriable := func(err error) bool { return rpc.ErrorClass(err) == rpc.ErrorClassApplication }
+	processor := &echoProcessor{failures: 2}
+	client := rpc.NewClient(
+		NewTransportFactory(processor),
+		rpc.RetrierOption(retry.NewRetrier(
+			retry.MaxAttemptsOption(3),
+			retry.BackoffOption(retry.NoopBackoff),
+			retry.RetriableOption(retriable),
+		)),
+	)
+
+	resp, err := invokeEcho(client, "hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp != "hello" || processor.calls != 3 {
+		t.Errorf("expected %q after 3 calls, received %q after %d", "hello", resp, processor.calls)
+	}
+
+	if _, err := invokeEcho(client, "missing"); err == nil || processor.calls != 4 {
+		t.Errorf("expected the declared exception without retries, received %v after %d calls", err, processor.calls-3)
+	}
+}
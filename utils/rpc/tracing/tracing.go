@@ -0,0 +1,117 @@
+// Package tracing creates OpenTelemetry spans for calls made through rpc.Clients.
+//
+// Every logical call gets a span, and every attempt made by the Retrier gets a child span carrying
+// the attempt number and the time spent backing off before it. When the client uses the THeader
+// protocol, see rpc.NewTHeaderProtocolFactory, the attempt's trace context is injected into the
+// thrift headers so servers can continue the trace.
+package tracing
+
+import (
+	"context"
+
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/oscarhealth/thriftgowrap/utils/rpc/tracing"
+
+// Attribute keys set on spans, in addition to the standard rpc.* ones.
+const (
+	AttemptKey    = attribute.Key("thrift.attempt")         // Zero-based attempt number, on attempt spans.
+	AttemptsKey   = attribute.Key("thrift.attempts")        // Attempts made, on call spans.
+	BackoffKey    = attribute.Key("thrift.backoff_seconds") // Backoff before the attempt, on attempt spans.
+	ErrorClassKey = attribute.Key("thrift.error_class")     // rpc.ErrorClass of a failure.
+)
+
+type tracer struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	tracer         trace.Tracer
+}
+
+// Option is an optional argument to ClientOption.
+type Option func(t *tracer)
+
+// TracerProviderOption sets the provider spans are created with.
+// Defaults to the global provider.
+func TracerProviderOption(tracerProvider trace.TracerProvider) Option {
+	return func(t *tracer) {
+		t.tracerProvider = tracerProvider
+	}
+}
+
+// PropagatorOption sets the propagator used to inject trace context into thrift headers.
+// Defaults to the global propagator.
+func PropagatorOption(propagator propagation.TextMapPropagator) Option {
+	return func(t *tracer) {
+		t.propagator = propagator
+	}
+}
+
+// ClientOption configures an rpc.Client to trace its calls.
+func ClientOption(options ...Option) rpc.ClientOption {
+	t := &tracer{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	t.tracer = t.tracerProvider.Tracer(instrumentationName)
+	return func(client *rpc.Client) {
+		rpc.InterceptorOption(t.interceptCall)(client)
+		rpc.AttemptInterceptorOption(t.interceptAttempt)(client)
+	}
+}
+
+func (t *tracer) interceptCall(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	ctx, span := t.tracer.Start(ctx, spanName(call), trace.WithAttributes(methodAttributes(call)...))
+	defer span.End()
+
+	result, err := next(ctx, call)
+	recordError(span, err)
+	return result, err
+}
+
+func (t *tracer) interceptAttempt(ctx context.Context, call *rpc.Call, next rpc.Invoker) (interface{}, error) {
+	trace.SpanFromContext(ctx).SetAttributes(AttemptsKey.Int64(int64(call.Attempt + 1)))
+
+	ctx, span := t.tracer.Start(ctx, spanName(call),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(methodAttributes(call)...),
+		trace.WithAttributes(AttemptKey.Int64(int64(call.Attempt)), BackoffKey.Float64(call.Wait.Seconds())),
+	)
+	defer span.End()
+
+	t.propagator.Inject(ctx, propagation.MapCarrier(call.Headers))
+	result, err := next(ctx, call)
+	recordError(span, err)
+	return result, err
+}
+
+func spanName(call *rpc.Call) string {
+	return call.Method.Service + "/" + call.Method.Name
+}
+
+func methodAttributes(call *rpc.Call) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "apache_thrift"),
+		attribute.String("rpc.service", call.Method.Service),
+		attribute.String("rpc.method", call.Method.Name),
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(ErrorClassKey.String(rpc.ErrorClass(err)))
+}
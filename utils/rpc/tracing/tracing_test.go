@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/oscarhealth/thriftgowrap/utils/retry"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc"
+	"github.com/oscarhealth/thriftgowrap/utils/rpc/rpctest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// headerProcessor replies to every call with an empty struct, recording the headers of each call.
+type headerProcessor struct {
+	headers []map[string]string
+}
+
+func (p *headerProcessor) Process(in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin()
+	if err != nil {
+		return false, err
+	}
+	p.headers = append(p.headers, in.(*rpc.THeaderProtocol).ReadHeaders())
+	in.Skip(thrift.STRUCT)
+	in.ReadMessageEnd()
+
+	out.WriteMessageBegin(name, thrift.REPLY, seqID)
+	writeEmptyStruct(out)
+	out.WriteMessageEnd()
+	return true, out.Flush()
+}
+
+func writeEmptyStruct(p thrift.TProtocol) {
+	p.WriteStructBegin("empty")
+	p.WriteFieldStop()
+	p.WriteStructEnd()
+}
+
+// emptyCall performs a call with empty args and result the way an Apache-generated client would.
+func emptyCall(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) error {
+	oprot, iprot := protocolFactory.GetProtocol(transport), protocolFactory.GetProtocol(transport)
+	oprot.WriteMessageBegin("test", thrift.CALL, 1)
+	writeEmptyStruct(oprot)
+	oprot.WriteMessageEnd()
+	if err := oprot.Flush(); err != nil {
+		return err
+	}
+	if _, _, _, err := iprot.ReadMessageBegin(); err != nil {
+		return err
+	}
+	return iprot.Skip(thrift.STRUCT)
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestClientOption(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	processor := &headerProcessor{}
+	client := rpc.NewClient(
+		rpctest.NewTransportFactory(processor, rpctest.ProtocolFactoryOption(rpc.NewTHeaderProtocolFactory())),
+		rpc.RetrierOption(retry.NewRetrier(retry.MaxAttemptsOption(2), retry.BackoffOption(retry.NoopBackoff))),
+		ClientOption(
+			TracerProviderOption(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+			PropagatorOption(propagation.TraceContext{}),
+		),
+	)
+
+	client.Invoke(context.Background(), &rpc.Method{Service: "TestService", Name: "test"}, nil,
+		func(transport thrift.TTransport, protocolFactory thrift.TProtocolFactory) (interface{}, error) {
+			if err := emptyCall(transport, protocolFactory); err != nil {
+				return nil, err
+			}
+			if len(processor.headers) == 1 {
+				return nil, errors.New("err")
+			}
+			return nil, nil
+		})
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 2 attempt spans and 1 call span, received %d spans", len(spans))
+	}
+	first, second, call := spans[0], spans[1], spans[2]
+	for i, attempt := range []sdktrace.ReadOnlySpan{first, second} {
+		if attempt.Name() != "TestService/test" {
+			t.Errorf("expected span name TestService/test, received %s", attempt.Name())
+		}
+		if attempt.Parent().SpanID() != call.SpanContext().SpanID() {
+			t.Errorf("expected attempt %d to be a child of the call span", i)
+		}
+		if value, _ := attributeValue(attempt, AttemptKey); value.AsInt64() != int64(i) {
+			t.Errorf("expected attempt %d to be numbered, received %v", i, value.AsInt64())
+		}
+		traceparent := processor.headers[i]["traceparent"]
+		if !strings.Contains(traceparent, attempt.SpanContext().SpanID().String()) {
+			t.Errorf("expected attempt %d to send its trace context to the server, received %q", i, traceparent)
+		}
+	}
+	if first.Status().Code != codes.Error || second.Status().Code == codes.Error {
+		t.Error("expected only the first attempt to fail")
+	}
+	if value, _ := attributeValue(call, AttemptsKey); value.AsInt64() != 2 {
+		t.Errorf("expected call span to record 2 attempts, received %d", value.AsInt64())
+	}
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidationError is returned for calls whose args are missing a required field, without being
+// sent.
+type ValidationError struct {
+	Method *Method
+	Field  string // The path of the missing field, as in Method.Required.
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s.%s: required field %s is not set", e.Method.Service, e.Method.Name, e.Field)
+}
+
+// validated returns an Invoker calling invoker only if the required fields of the call's args are
+// set.
+func validated(invoker Invoker) Invoker {
+	return func(ctx context.Context, call *Call) (interface{}, error) {
+		for _, path := range call.Method.Required {
+			if missing(reflect.ValueOf(call.Args), strings.Split(path, ".")) {
+				return nil, &ValidationError{Method: call.Method, Field: path}
+			}
+		}
+		return invoker(ctx, call)
+	}
+}
+
+// missing returns whether the field at path within value is a nil struct, the only required fields
+// that can be unset: the thrift generator gives required fields of other types non-pointer go
+// types, and writes nil lists, sets, maps and binaries as empty. Fields within unset structs are
+// not missing, as they are only required once their struct is set; fields within lists, sets and
+// maps are missing if they are for any element.
+func missing(value reflect.Value, path []string) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !value.IsNil() && missing(value.Elem(), path)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if missing(value.Index(i), path) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			if missing(iter.Key(), path) || missing(iter.Value(), path) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		field, ok := thriftField(value, path[0])
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			return field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct && field.IsNil()
+		}
+		return missing(field, path[1:])
+	}
+	return false
+}
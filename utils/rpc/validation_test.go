@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// owner is a thrift gen struct.
+type owner struct {
+	ID int64 `thrift:"id,1,required" db:"id" json:"id"`
+}
+
+// userRequest is a thrift gen struct, with required fields of several types and an optional parent.
+type userRequest struct {
+	Name   string       `thrift:"name,1,required" db:"name" json:"name"`
+	Owner  *owner       `thrift:"owner,2,required" db:"owner" json:"owner"`
+	Tags   []string     `thrift:"tags,3,required" db:"tags" json:"tags"`
+	Parent *userRequest `thrift:"parent,4" db:"parent" json:"parent,omitempty"`
+}
+
+// updateArgs is a thrift gen args struct holding userRequests.
+type updateArgs struct {
+	*idArgs
+	Request  *userRequest   `thrift:"request,1" db:"request" json:"request"`
+	Requests []*userRequest `thrift:"requests,2" db:"requests" json:"requests"`
+}
+
+func TestClient_Validation(t *testing.T) {
+	method := &Method{
+		Service: "TestService",
+		Name:    "update",
+		Required: []string{
+			"request.name", "request.owner", "request.tags", "request.parent.owner", "requests.owner",
+		},
+	}
+	client := NewClient(&memoryTransportFactory{})
+
+	validations := []struct {
+		args    *updateArgs
+		missing string
+	}{
+		{&updateArgs{Request: &userRequest{Name: "name", Owner: &owner{}, Tags: []string{}}}, ""},
+		{&updateArgs{}, ""},
+		// zero values and nil lists are sent as they are
+		{&updateArgs{Request: &userRequest{Owner: &owner{}}}, ""},
+		{&updateArgs{Request: &userRequest{Name: "name"}}, "request.owner"},
+		{&updateArgs{Request: &userRequest{Owner: &owner{}, Parent: &userRequest{}}}, "request.parent.owner"},
+		{&updateArgs{Requests: []*userRequest{{Owner: &owner{}}, {}}}, "requests.owner"},
+	}
+
+	for _, tc := range validations {
+		attempts := 0
+		_, err := client.Invoke(context.Background(), method, tc.args,
+			func(thrift.TTransport, thrift.TProtocolFactory) (interface{}, error) {
+				attempts++
+				return nil, nil
+			})
+		if tc.missing == "" {
+			if err != nil || attempts != 1 {
+				t.Errorf("expected %+v to be sent, received %v after %d attempts", tc.args, err, attempts)
+			}
+			continue
+		}
+		validationErr, ok := err.(*ValidationError)
+		if !ok || validationErr.Field != tc.missing || attempts != 0 {
+			t.Errorf("expected a ValidationError for %s without attempts, received %v after %d attempts",
+				tc.missing, err, attempts)
+		}
+	}
+}